@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/api"
+	"Avito_task/internal/auth"
+	"Avito_task/internal/cache"
+	"Avito_task/internal/configs"
+	"Avito_task/internal/db"
+	"Avito_task/internal/db/memory"
+	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
+	"Avito_task/internal/usecase"
+)
+
+// benchOptions содержит параметры нагрузочного прогона
+type benchOptions struct {
+	configPath  string
+	storage     string
+	dsn         string
+	features    int
+	tags        int
+	requests    int
+	concurrency int
+	cache       string
+	sli         time.Duration
+}
+
+// benchResult содержит итоги одного прогона
+type benchResult struct {
+	mode      string
+	latencies []time.Duration
+	errors    int64
+	elapsed   time.Duration
+}
+
+// runBench наполняет хранилище баннерами и измеряет задержки /user_banner внутри процесса
+func runBench(args []string) {
+	opts := benchOptions{}
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.StringVar(&opts.configPath, "config", "internal/configs/config.yaml", "путь к файлу конфигурации")
+	fs.StringVar(&opts.storage, "storage", "memory", "хранилище: memory или postgres (база из -dsn)")
+	fs.StringVar(&opts.dsn, "dsn", "", "строка подключения к отдельной базе PostgreSQL в формате key=value; обязательна для -storage postgres")
+	fs.IntVar(&opts.features, "features", 100, "количество фич")
+	fs.IntVar(&opts.tags, "tags", 10, "количество тегов на фичу")
+	fs.IntVar(&opts.requests, "requests", 100000, "количество запросов в каждом прогоне")
+	fs.IntVar(&opts.concurrency, "concurrency", 50, "количество параллельных клиентов")
+	fs.StringVar(&opts.cache, "cache", "both", "режим кэша: on, off или both")
+	fs.DurationVar(&opts.sli, "sli", 50*time.Millisecond, "целевая задержка p99")
+	fs.Parse(args)
+
+	if opts.features <= 0 || opts.tags <= 0 || opts.requests <= 0 || opts.concurrency <= 0 {
+		fatal("некорректные параметры нагрузки", fmt.Errorf("features, tags, requests и concurrency должны быть положительными"))
+	}
+
+	var modes []bool
+	switch opts.cache {
+	case "on":
+		modes = []bool{true}
+	case "off":
+		modes = []bool{false}
+	case "both":
+		modes = []bool{true, false}
+	default:
+		fatal("некорректный режим кэша", fmt.Errorf("ожидается on, off или both, получено %q", opts.cache))
+	}
+
+	cfg, err := configs.Load(opts.configPath)
+	if err != nil {
+		fatal("ошибка загрузки конфигурации", err)
+	}
+
+	// Журнал каждого запроса исказил бы замеры, поэтому выводятся только ошибки
+	logging.New(os.Stderr, "error")
+	gin.SetMode(gin.ReleaseMode)
+
+	bannerRepo, statsRepo, closeStorage := openBenchStorage(opts.storage, opts.dsn)
+	defer closeStorage()
+
+	// fatal завершает процесс без отложенных вызовов, поэтому хранилище закрывается до выхода,
+	// в том числе при прерывании прогона сигналом
+	fail := func(msg string, err error) {
+		closeStorage()
+		fatal(msg, err)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		fail("прогон прерван", fmt.Errorf("получен сигнал %s", sig))
+	}()
+
+	ctx := context.Background()
+	start := time.Now()
+	if err := seedBanners(ctx, bannerRepo, opts.features, opts.tags); err != nil {
+		fail("ошибка наполнения хранилища", err)
+	}
+	fmt.Printf("создано баннеров: %d за %s\n", opts.features*opts.tags, time.Since(start).Round(time.Millisecond))
+
+	tokenService := auth.NewTokenService([]byte(cfg.JWT.Secret))
+	token, err := tokenService.GenerateToken(1, entity.RoleUser, nil)
+	if err != nil {
+		fail("ошибка создания токена", err)
+	}
+
+	// Ограничения частоты запросов не задаются: прогон идет от имени одного пользователя
+	routerConfig := api.RouterConfig{
+		RequestTimeout: time.Duration(cfg.Server.RequestTimeoutMs) * time.Millisecond,
+		RouteTimeouts:  make(map[string]time.Duration),
+	}
+	for route, timeoutMs := range cfg.Server.RouteTimeoutsMs {
+		routerConfig.RouteTimeouts[route] = time.Duration(timeoutMs) * time.Millisecond
+	}
+
+	var results []benchResult
+	for _, cacheEnabled := range modes {
+		var bannerCache usecase.BannerCache
+		mode := "cache off"
+		if cacheEnabled {
+			bannerCache = cache.NewMemoryBannerCache(time.Duration(cfg.Cache.TTLSeconds) * time.Second)
+			mode = "cache on"
+		}
+
+		// Статистика показов копится в буфере и не сохраняется, чтобы не влиять на замеры
+		bannerUseCase := usecase.NewBannerUseCase(bannerRepo, nil, tokenService, bannerCache, nil, nil)
+		bannerScheduler := usecase.NewBannerScheduler(bannerRepo, tokenService, time.Minute, time.Hour)
+		statsUseCase := usecase.NewStatsUseCase(statsRepo, bannerRepo, tokenService, time.Minute, cfg.Stats.BatchSize)
+		router, err := api.SetupRouter(
+			api.NewBannerHandlers(bannerUseCase, bannerScheduler, statsUseCase),
+			api.NewAuditHandlers(usecase.NewAuditUseCase(memory.NewAuditRepository(), tokenService)),
+			api.NewUserHandlers(usecase.NewUserUseCase(memory.NewUserRepository(), memory.NewRoleRepository(), memory.NewGrantRepository(), tokenService, usecase.DefaultUserPolicy(), nil)),
+			api.NewAPIKeyHandlers(usecase.NewAPIKeyUseCase(memory.NewAPIKeyRepository(), tokenService, nil)),
+			routerConfig,
+		)
+		if err != nil {
+			fail("ошибка настройки маршрутизатора", err)
+		}
+
+		result := driveUserBanner(router, token, opts)
+		result.mode = mode
+		results = append(results, result)
+	}
+
+	printBenchResults(os.Stdout, results, opts.sli)
+}
+
+// openBenchStorage открывает хранилище для прогона и возвращает функцию его закрытия, которую можно вызывать повторно.
+// Для postgres прогон идет во временной схеме базы из dsn, а закрытие удаляет схему вместе с созданными баннерами
+func openBenchStorage(storage, dsn string) (usecase.BannerRepository, usecase.StatsRepository, func()) {
+	switch storage {
+	case "memory":
+		return memory.NewBannerRepository(), memory.NewStatsRepository(), func() {}
+	case "postgres":
+		// База сервиса из конфигурации не используется, чтобы прогон не смешивал свои баннеры с рабочими
+		if dsn == "" {
+			fatal("не задана база для прогона", fmt.Errorf("для -storage postgres нужен -dsn отдельной базы"))
+		}
+
+		admin, err := sql.Open("postgres", dsn)
+		if err != nil {
+			fatal("ошибка подключения к базе данных", err)
+		}
+		schema := fmt.Sprintf("bench_%d_%d", os.Getpid(), time.Now().UnixNano())
+		if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+			admin.Close()
+			fatal("ошибка создания временной схемы", err)
+		}
+
+		database, err := sql.Open("postgres", dsn+" search_path="+schema)
+		closeStorage := sync.OnceFunc(func() {
+			if database != nil {
+				database.Close()
+			}
+			if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+				slog.Error("ошибка удаления временной схемы", "schema", schema, "error", err)
+			}
+			admin.Close()
+		})
+		if err != nil {
+			closeStorage()
+			fatal("ошибка подключения к базе данных", err)
+		}
+		if err := db.NewDBManager(database).SetupTables(); err != nil {
+			closeStorage()
+			fatal("ошибка создания таблиц", err)
+		}
+		return db.NewBannerRepository(database), db.NewStatsRepository(database), closeStorage
+	default:
+		fatal("некорректное хранилище", fmt.Errorf("ожидается memory или postgres, получено %q", storage))
+		return nil, nil, nil
+	}
+}
+
+// seedBanners создает по одному активному баннеру на каждую пару фичи и тега
+func seedBanners(ctx context.Context, bannerRepo usecase.BannerRepository, features, tags int) error {
+	for featureID := 1; featureID <= features; featureID++ {
+		for tagID := 1; tagID <= tags; tagID++ {
+			banner := &entity.Banner{
+				JSONStructure: fmt.Sprintf(`{"title":"banner %d-%d","url":"https://avito.ru/"}`, featureID, tagID),
+				FeatureID:     featureID,
+				TagIDs:        []int{tagID},
+				IsActive:      true,
+			}
+			if err := bannerRepo.CreateBanner(ctx, banner); err != nil {
+				return fmt.Errorf("фича %d, тег %d: %w", featureID, tagID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// driveUserBanner выполняет запросы /user_banner к случайным парам фичи и тега из нескольких горутин
+func driveUserBanner(handler http.Handler, token string, opts benchOptions) benchResult {
+	latencies := make([]time.Duration, opts.requests)
+	var next, failures int64
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for worker := 0; worker < opts.concurrency; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+
+			for {
+				i := atomic.AddInt64(&next, 1) - 1
+				if i >= int64(opts.requests) {
+					return
+				}
+
+				url := fmt.Sprintf("/user_banner?feature_id=%d&tag_id=%d", rnd.Intn(opts.features)+1, rnd.Intn(opts.tags)+1)
+				req := httptest.NewRequest(http.MethodGet, url, nil)
+				req.Header.Set("Authorization", token)
+				rec := httptest.NewRecorder()
+
+				requestStart := time.Now()
+				handler.ServeHTTP(rec, req)
+				latencies[i] = time.Since(requestStart)
+
+				if rec.Code != http.StatusOK {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+		}(int64(worker))
+	}
+	wg.Wait()
+
+	return benchResult{latencies: latencies, errors: failures, elapsed: time.Since(start)}
+}
+
+// printBenchResults выводит задержки и долю ошибок каждого прогона
+func printBenchResults(w io.Writer, results []benchResult, sli time.Duration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "режим\tзапросов\tRPS\tp50\tp95\tp99\tmax\tошибки\tSLI")
+
+	for _, r := range results {
+		sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+		p99 := percentile(r.latencies, 0.99)
+		verdict := "ok"
+		if p99 > sli {
+			verdict = "нарушен"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%s\t%s\t%s\t%s\t%.2f%%\t%s\n",
+			r.mode,
+			len(r.latencies),
+			float64(len(r.latencies))/r.elapsed.Seconds(),
+			percentile(r.latencies, 0.50),
+			percentile(r.latencies, 0.95),
+			p99,
+			r.latencies[len(r.latencies)-1],
+			100*float64(r.errors)/float64(len(r.latencies)),
+			verdict,
+		)
+	}
+
+	tw.Flush()
+}
+
+// percentile возвращает перцентиль p отсортированной выборки задержек
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(float64(len(sorted))*p)) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
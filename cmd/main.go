@@ -1,14 +1,262 @@
 package main
 
 import (
-    "github.com/gin-gonic/gin"
-    "C:\Users\marwq\Avito_task\internal\api" // Импортируйте пакет api из вашего проекта
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"Avito_task/internal/api"
+	"Avito_task/internal/auth"
+	"Avito_task/internal/cache"
+	"Avito_task/internal/configs"
+	"Avito_task/internal/db"
+	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
+	"Avito_task/internal/metrics"
+	"Avito_task/internal/usecase"
 )
 
 func main() {
-    // Инициализация Gin router
-    router := api.SetupRouter()
+	// Подкоманда bench запускает нагрузочный прогон вместо сервера
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+
+	configPath := flag.String("config", "internal/configs/config.yaml", "путь к файлу конфигурации")
+	flag.Parse()
+
+	// Загрузка конфигурации
+	cfg, err := configs.Load(*configPath)
+	if err != nil {
+		fatal("ошибка загрузки конфигурации", err)
+	}
+
+	// Структурированное логирование и порог медленных запросов
+	logging.New(os.Stdout, cfg.Logging.Level)
+	db.SlowQueryThreshold = time.Duration(cfg.Database.SlowQueryThresholdMs) * time.Millisecond
+
+	// Подключение к базе данных PostgreSQL
+	database, err := sql.Open("postgres", cfg.Database.DSN())
+	if err != nil {
+		fatal("ошибка подключения к базе данных", err)
+	}
+	defer database.Close()
+
+	if err := db.NewDBManager(database).SetupTables(); err != nil {
+		fatal("ошибка создания таблиц", err)
+	}
+	metrics.RegisterDB(database, cfg.Database.DBName)
+
+	// Инициализация репозиториев и сервисов
+	tokenService := auth.NewTokenService([]byte(cfg.JWT.Secret))
+	bannerRepo := db.NewBannerRepository(database)
+	bannerRepo.Origin = newEventOrigin()
+	grantRepo := db.NewGrantRepository(database)
+	var bannerCache usecase.BannerCache
+	if cfg.Cache.Enabled {
+		bannerCache = newBannerCache(cfg.Cache)
+	}
+	var bannerSnapshot *usecase.BannerSnapshot
+	if cfg.Snapshot.Enabled {
+		bannerSnapshot = newBannerSnapshot(bannerRepo, cfg.Snapshot)
+	}
+	auditUseCase := usecase.NewAuditUseCase(db.NewAuditRepository(database), tokenService)
+	bannerUseCase := usecase.NewBannerUseCase(bannerRepo, grantRepo, tokenService, bannerCache, bannerSnapshot, auditUseCase)
+	userPolicy := usecase.UserPolicy{
+		MinPasswordLength: cfg.Users.MinPasswordLength,
+		MaxFailedLogins:   cfg.Users.MaxFailedLogins,
+		LockoutBase:       time.Duration(cfg.Users.LockoutBaseSeconds) * time.Second,
+		LockoutMax:        time.Duration(cfg.Users.LockoutMaxMinutes) * time.Minute,
+	}
+	userUseCase := usecase.NewUserUseCase(db.NewUserRepository(database), db.NewRoleRepository(database), grantRepo, tokenService, userPolicy, auditUseCase)
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(db.NewAPIKeyRepository(database), tokenService, auditUseCase)
+
+	// Сервер останавливается по сигналу завершения, фоновые процессы - после него
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	// Фоновые процессы учитываются в workers, чтобы база закрылась только после их остановки
+	var workers sync.WaitGroup
+	startWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+
+	// Запуск планировщика окон показа баннеров
+	bannerScheduler := usecase.NewBannerScheduler(bannerRepo, tokenService,
+		time.Duration(cfg.Scheduler.IntervalSeconds)*time.Second,
+		time.Duration(cfg.Scheduler.HorizonHours)*time.Hour)
+	startWorker(bannerScheduler.Run)
+
+	// Запуск фонового сохранения статистики показов и кликов
+	statsUseCase := usecase.NewStatsUseCase(db.NewStatsRepository(database), bannerRepo, tokenService,
+		time.Duration(cfg.Stats.FlushIntervalSeconds)*time.Second, cfg.Stats.BatchSize)
+	startWorker(statsUseCase.Run)
+
+	// Запуск обновления снимка баннеров пользователей
+	if bannerSnapshot != nil {
+		startWorker(bannerSnapshot.Run)
+	}
+
+	// Запуск ленты изменений баннеров: изменения, сделанные другими репликами, обновляют снимок и кэш в памяти
+	var bannerEvents *usecase.BannerEventBus
+	if cfg.Events.Enabled {
+		bannerEvents = usecase.NewBannerEventBus()
+		if bannerSnapshot != nil {
+			bannerEvents.Subscribe(workersCtx, "snapshot", func(context.Context, entity.BannerEvent) {
+				bannerSnapshot.Notify()
+			})
+		}
+		// Расписание перечитывается при изменении окон показа на любой реплике, не дожидаясь тикера;
+		// окончательное удаление уже удаленного баннера расписание не меняет
+		bannerEvents.Subscribe(workersCtx, "scheduler", func(ctx context.Context, event entity.BannerEvent) {
+			if event.Change == entity.BannerEventPurge {
+				return
+			}
+			if err := bannerScheduler.Refresh(ctx, time.Now()); err != nil {
+				slog.Error("ошибка при обновлении расписания баннеров", "error", err)
+			}
+		})
+		// Redis кэш очищает реплика, изменившая баннер; кэш в памяти другой реплики удаляет записи баннера из события,
+		// а свои изменения реплика уже удалила из кэша сама
+		if memoryCache, ok := bannerCache.(*cache.MemoryBannerCache); ok {
+			bannerEvents.Subscribe(workersCtx, "cache", func(_ context.Context, event entity.BannerEvent) {
+				switch {
+				case event.Change == entity.BannerEventResync:
+					memoryCache.Clear()
+				case event.Origin != bannerRepo.Origin:
+					memoryCache.InvalidateBanner(event.BannerID, event.FeatureID, event.TagIDs)
+				}
+			})
+		}
+		bannerListener := db.NewBannerListener(cfg.Database.DSN(), bannerEvents)
+		bannerListener.MinReconnect = time.Duration(cfg.Events.MinReconnectSeconds) * time.Second
+		bannerListener.MaxReconnect = time.Duration(cfg.Events.MaxReconnectSeconds) * time.Second
+		startWorker(bannerListener.Run)
+	}
+
+	// Запуск окончательного удаления баннеров, удаленных мягко раньше срока хранения
+	bannerRetention := usecase.NewBannerRetention(bannerRepo,
+		time.Duration(cfg.Retention.DeletedBannerDays)*24*time.Hour,
+		time.Duration(cfg.Retention.IntervalMinutes)*time.Minute)
+	startWorker(bannerRetention.Run)
+
+	// Инициализация Gin router
+	routerConfig := api.RouterConfig{
+		RequestTimeout: time.Duration(cfg.Server.RequestTimeoutMs) * time.Millisecond,
+		RouteTimeouts:  make(map[string]time.Duration),
+		RateLimits:     make(map[string]api.RateLimit),
+		TokenService:   tokenService,
+		RequireIfMatch: cfg.Server.RequireIfMatch,
+		TrustedProxies: cfg.Server.TrustedProxies,
+	}
+	for route, timeoutMs := range cfg.Server.RouteTimeoutsMs {
+		routerConfig.RouteTimeouts[route] = time.Duration(timeoutMs) * time.Millisecond
+	}
+	for group, limit := range cfg.Server.RateLimits {
+		routerConfig.RateLimits[group] = api.RateLimit{RequestsPerSecond: limit.RequestsPerSecond, Burst: limit.Burst}
+	}
+	router, err := api.SetupRouter(
+		api.NewBannerHandlers(bannerUseCase, bannerScheduler, statsUseCase),
+		api.NewAuditHandlers(auditUseCase),
+		api.NewUserHandlers(userUseCase),
+		api.NewAPIKeyHandlers(apiKeyUseCase),
+		routerConfig,
+	)
+	if err != nil {
+		fatal("ошибка настройки маршрутизатора", err)
+	}
+
+	// Запуск HTTP сервера
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("ошибка HTTP сервера", err)
+		}
+	}()
+
+	// Корректное завершение: дожидаемся текущих запросов и остановки фоновых процессов, в том числе сохранения
+	// статистики; отложенное закрытие базы выполняется уже после них
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("ошибка при остановке сервера", "error", err)
+	}
+	stopWorkers()
+	workers.Wait()
+	if bannerEvents != nil {
+		bannerEvents.Wait()
+	}
+}
+
+// newBannerCache создает кэш баннеров пользователей выбранного в конфигурации типа
+func newBannerCache(cfg configs.CacheConfig) usecase.BannerCache {
+	ttl := time.Duration(cfg.TTLSeconds) * time.Second
+	switch cfg.Backend {
+	case "", "memory":
+		return cache.NewMemoryBannerCache(ttl)
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			fatal("ошибка подключения к Redis", err)
+		}
+		return cache.NewRedisBannerCache(client, ttl, cfg.Redis.KeyPrefix)
+	default:
+		fatal("неизвестный тип кэша баннеров", fmt.Errorf("%q", cfg.Backend))
+		return nil
+	}
+}
+
+// newBannerSnapshot создает снимок баннеров пользователей и загружает его до запуска сервера
+func newBannerSnapshot(bannerRepo usecase.BannerRepository, cfg configs.SnapshotConfig) *usecase.BannerSnapshot {
+	snapshot := usecase.NewBannerSnapshot(bannerRepo, time.Duration(cfg.RefreshIntervalSeconds)*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := snapshot.Refresh(ctx); err != nil {
+		fatal("ошибка загрузки снимка баннеров", err)
+	}
+	return snapshot
+}
+
+// newEventOrigin создает случайный идентификатор реплики для событий ленты изменений баннеров
+func newEventOrigin() string {
+	origin := make([]byte, 8)
+	if _, err := rand.Read(origin); err != nil {
+		fatal("ошибка генерации идентификатора реплики", err)
+	}
+	return hex.EncodeToString(origin)
+}
 
-    // Запуск HTTP сервера
-    router.Run(":8080")
+// fatal логирует ошибку запуска и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+// APIKeyHeader является заголовком, в котором сервисы передают API ключ вместо токена в Authorization
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyHandlers представляет обработчики запросов для управления API ключами
+type APIKeyHandlers struct {
+	APIKeyUseCase *usecase.APIKeyUseCase
+}
+
+// NewAPIKeyHandlers создает новый экземпляр APIKeyHandlers
+func NewAPIKeyHandlers(apiKeyUseCase *usecase.APIKeyUseCase) *APIKeyHandlers {
+	return &APIKeyHandlers{APIKeyUseCase: apiKeyUseCase}
+}
+
+// apiKeyMiddleware принимает API ключ из заголовка X-API-Key и подставляет вместо него в Authorization
+// токен на время запроса, поэтому обработчики и ограничение частоты запросов не различают ключи и токены.
+// Ключ имеет приоритет над переданным вместе с ним токеном. Проверка ключа обращается к хранилищу,
+// поэтому запросы с ключом заранее ограничиваются по IP клиента согласно limit
+func apiKeyMiddleware(apiKeyUseCase *usecase.APIKeyUseCase, limit RateLimit) gin.HandlerFunc {
+	var limiter *rateLimiter
+	if limit.RequestsPerSecond > 0 {
+		limiter = newRateLimiter(limit)
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if limiter != nil {
+			if ok, wait := limiter.allow("ip:"+c.ClientIP(), time.Now()); !ok {
+				rejectRateLimited(c, wait)
+				return
+			}
+		}
+
+		token, err := apiKeyUseCase.AuthenticateAPIKey(c.Request.Context(), key)
+		if err != nil {
+			switch {
+			case errors.Is(err, usecase.ErrUnauthorized):
+				respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+			default:
+				respondInternalError(c, err)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Request.Header.Set("Authorization", token)
+		c.Next()
+	}
+}
+
+// GetAPIKeysHandler обработчик для получения списка API ключей
+func (h *APIKeyHandlers) GetAPIKeysHandler(c *gin.Context) {
+	token := c.GetHeader("Authorization")
+	keys, err := h.APIKeyUseCase.GetAPIKeys(c.Request.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKeyHandler обработчик для создания API ключа; значение ключа возвращается только в этом ответе
+func (h *APIKeyHandlers) CreateAPIKeyHandler(c *gin.Context) {
+	var req entity.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	created, err := h.APIKeyUseCase.CreateAPIKey(c.Request.Context(), req.Name, req.Scopes, req.ExpiresAt, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteAPIKeyHandler обработчик для отзыва API ключа
+func (h *APIKeyHandlers) DeleteAPIKeyHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.APIKeyUseCase.DeleteAPIKey(c.Request.Context(), id, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrAPIKeyNotFound):
+			respondError(c, http.StatusNotFound, CodeAPIKeyNotFound)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,2344 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"Avito_task/internal/api"
+	"Avito_task/internal/auth"
+	"Avito_task/internal/cache"
+	"Avito_task/internal/db"
+	"Avito_task/internal/db/memory"
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+// testPostgresDSNEnv задает строку подключения к PostgreSQL в формате key=value; без нее тесты
+// запускают одноразовый кластер PostgreSQL из initdb и pg_ctl
+const testPostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// testPostgresSkipEnv явно разрешает пропускать тесты на PostgreSQL, если он не установлен;
+// без нее отсутствие PostgreSQL считается ошибкой, чтобы проверки на нем не выпадали незаметно
+const testPostgresSkipEnv = "TEST_POSTGRES_SKIP"
+
+// errPostgresNotInstalled означает, что для одноразового кластера не найдены initdb и pg_ctl
+var errPostgresNotInstalled = errors.New("initdb и pg_ctl не найдены")
+
+// disposablePostgres описывает одноразовый кластер PostgreSQL, общий для всех тестов пакета
+var disposablePostgres struct {
+	once  sync.Once
+	dir   string
+	pgCtl string
+	dsn   string
+	err   error
+
+	// skipped содержит имена тестов, пропущенных без PostgreSQL, для итогового предупреждения
+	mu      sync.Mutex
+	skipped []string
+}
+
+// testEnv представляет запущенный HTTP стек сервиса и токены для обращения к нему
+type testEnv struct {
+	server     *httptest.Server
+	repos      storage
+	adminToken string
+	userToken  string
+}
+
+// storage содержит репозитории, поверх которых запускается HTTP стек
+type storage struct {
+	banners usecase.BannerRepository
+	stats   usecase.StatsRepository
+	audit   usecase.AuditRepository
+	users   usecase.UserRepository
+	roles   usecase.RoleRepository
+	grants  usecase.GrantRepository
+	apiKeys usecase.APIKeyRepository
+	// cache задает кэш баннеров пользователей; без него используется кэш в памяти с TTL 5 минут
+	cache usecase.BannerCache
+	// snapshot задает снимок баннеров пользователей; без него баннеры читаются из кэша и репозитория
+	snapshot *usecase.BannerSnapshot
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	stopDisposablePostgres()
+	reportSkippedPostgres()
+	os.Exit(code)
+}
+
+// reportSkippedPostgres печатает в stderr предупреждение о пропущенных проверках на PostgreSQL,
+// чтобы они были видны и без -v
+func reportSkippedPostgres() {
+	disposablePostgres.mu.Lock()
+	defer disposablePostgres.mu.Unlock()
+
+	if len(disposablePostgres.skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "ВНИМАНИЕ: %d тестов на PostgreSQL пропущено (%v): задайте %s или установите PostgreSQL, см. README\n",
+		len(disposablePostgres.skipped), disposablePostgres.err, testPostgresDSNEnv)
+	for _, name := range disposablePostgres.skipped {
+		fmt.Fprintf(os.Stderr, "\t%s\n", name)
+	}
+}
+
+// postgresDSN возвращает строку подключения к PostgreSQL: из TEST_POSTGRES_DSN или к одноразовому кластеру.
+// Если PostgreSQL не установлен, тест падает, а при заданной TEST_POSTGRES_SKIP пропускается
+// и попадает в итоговое предупреждение TestMain
+func postgresDSN(t *testing.T) string {
+	t.Helper()
+
+	if dsn := os.Getenv(testPostgresDSNEnv); dsn != "" {
+		return dsn
+	}
+
+	disposablePostgres.once.Do(startDisposablePostgres)
+	err := disposablePostgres.err
+	if err == nil {
+		return disposablePostgres.dsn
+	}
+	if errors.Is(err, errPostgresNotInstalled) && os.Getenv(testPostgresSkipEnv) != "" {
+		disposablePostgres.mu.Lock()
+		disposablePostgres.skipped = append(disposablePostgres.skipped, t.Name())
+		disposablePostgres.mu.Unlock()
+		t.Skipf("PostgreSQL недоступен: задайте %s или установите PostgreSQL (%v)", testPostgresDSNEnv, err)
+	}
+	t.Fatalf("не удалось подготовить PostgreSQL: %v; задайте %s, установите PostgreSQL или разрешите пропуск через %s=1",
+		err, testPostgresDSNEnv, testPostgresSkipEnv)
+	return ""
+}
+
+// startDisposablePostgres создает кластер во временном каталоге и запускает его только на unix-сокете
+func startDisposablePostgres() {
+	initdb, pgCtl, err := findPostgresBinaries()
+	if err != nil {
+		disposablePostgres.err = err
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "avito-pg-")
+	if err != nil {
+		disposablePostgres.err = err
+		return
+	}
+	disposablePostgres.dir = dir
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput()
+	if err != nil {
+		disposablePostgres.err = fmt.Errorf("initdb: %w: %s", err, out)
+		return
+	}
+	out, err = exec.Command(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-w",
+		"-o", "-k "+dir+" -c listen_addresses='' -F", "start").CombinedOutput()
+	if err != nil {
+		disposablePostgres.err = fmt.Errorf("pg_ctl start: %w: %s", err, out)
+		return
+	}
+
+	disposablePostgres.pgCtl = pgCtl
+	disposablePostgres.dsn = "host=" + dir + " user=postgres dbname=postgres sslmode=disable"
+}
+
+// stopDisposablePostgres останавливает одноразовый кластер, если он запускался, и удаляет его каталог
+func stopDisposablePostgres() {
+	if disposablePostgres.pgCtl != "" {
+		exec.Command(disposablePostgres.pgCtl, "-D", filepath.Join(disposablePostgres.dir, "data"), "-m", "immediate", "stop").Run()
+	}
+	if disposablePostgres.dir != "" {
+		os.RemoveAll(disposablePostgres.dir)
+	}
+}
+
+// findPostgresBinaries ищет initdb и pg_ctl в PATH и в каталогах пакетов PostgreSQL для Debian и Ubuntu
+func findPostgresBinaries() (string, string, error) {
+	dirs := []string{""}
+	versions, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	dirs = append(dirs, versions...)
+
+	for _, dir := range dirs {
+		initdb, pgCtl := "initdb", "pg_ctl"
+		if dir != "" {
+			initdb, pgCtl = filepath.Join(dir, initdb), filepath.Join(dir, pgCtl)
+		}
+		initdbPath, err := exec.LookPath(initdb)
+		if err != nil {
+			continue
+		}
+		pgCtlPath, err := exec.LookPath(pgCtl)
+		if err != nil {
+			continue
+		}
+		return initdbPath, pgCtlPath, nil
+	}
+
+	return "", "", errPostgresNotInstalled
+}
+
+// forEachStorage запускает тест на in-memory хранилище и на одноразовой схеме PostgreSQL
+func forEachStorage(t *testing.T, test func(t *testing.T, env *testEnv)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, newTestEnv(t, newMemoryStorage()))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		database := openDisposableSchema(t, postgresDSN(t))
+		test(t, newTestEnv(t, storage{
+			banners: db.NewBannerRepository(database),
+			stats:   db.NewStatsRepository(database),
+			audit:   db.NewAuditRepository(database),
+			users:   db.NewUserRepository(database),
+			roles:   db.NewRoleRepository(database),
+			grants:  db.NewGrantRepository(database),
+			apiKeys: db.NewAPIKeyRepository(database),
+		}))
+	})
+}
+
+// newMemoryStorage создает пустые in-memory репозитории
+func newMemoryStorage() storage {
+	return storage{
+		banners: memory.NewBannerRepository(),
+		stats:   memory.NewStatsRepository(),
+		audit:   memory.NewAuditRepository(),
+		users:   memory.NewUserRepository(),
+		roles:   memory.NewRoleRepository(),
+		grants:  memory.NewGrantRepository(),
+		apiKeys: memory.NewAPIKeyRepository(),
+	}
+}
+
+// openDisposableSchema создает отдельную схему PostgreSQL с таблицами сервиса и удаляет ее после теста
+func openDisposableSchema(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("не удалось создать схему: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	database, err := sql.Open("postgres", dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := db.NewDBManager(database).SetupTables(); err != nil {
+		t.Fatalf("не удалось создать таблицы: %v", err)
+	}
+
+	return database
+}
+
+// newTestEnv собирает сценарии использования и маршрутизатор поверх репозиториев и запускает httptest.Server
+func newTestEnv(t *testing.T, repos storage) *testEnv {
+	t.Helper()
+	return newTestEnvWithConfig(t, repos, api.RouterConfig{RequestTimeout: 5 * time.Second})
+}
+
+// newTestEnvWithConfig работает как newTestEnv, но с заданными настройками маршрутизатора
+func newTestEnvWithConfig(t *testing.T, repos storage, cfg api.RouterConfig) *testEnv {
+	t.Helper()
+
+	tokenService := auth.NewTokenService([]byte("test-secret"))
+	cfg.TokenService = tokenService
+	auditUseCase := usecase.NewAuditUseCase(repos.audit, tokenService)
+	bannerCache := repos.cache
+	if bannerCache == nil {
+		bannerCache = cache.NewMemoryBannerCache(5 * time.Minute)
+	}
+	bannerUseCase := usecase.NewBannerUseCase(repos.banners, repos.grants, tokenService, bannerCache, repos.snapshot, auditUseCase)
+	bannerScheduler := usecase.NewBannerScheduler(repos.banners, tokenService, time.Minute, 24*time.Hour)
+	statsUseCase := usecase.NewStatsUseCase(repos.stats, repos.banners, tokenService, time.Minute, 1000)
+
+	userUseCase := usecase.NewUserUseCase(repos.users, repos.roles, repos.grants, tokenService, usecase.DefaultUserPolicy(), auditUseCase)
+
+	router, err := api.SetupRouter(
+		api.NewBannerHandlers(bannerUseCase, bannerScheduler, statsUseCase),
+		api.NewAuditHandlers(auditUseCase),
+		api.NewUserHandlers(userUseCase),
+		api.NewAPIKeyHandlers(usecase.NewAPIKeyUseCase(repos.apiKeys, tokenService, auditUseCase)),
+		cfg,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	adminToken, err := tokenService.GenerateToken(1, entity.RoleAdmin, entity.BuiltinRolePermissions(entity.RoleAdmin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userToken, err := tokenService.GenerateToken(2, entity.RoleUser, entity.BuiltinRolePermissions(entity.RoleUser))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testEnv{server: server, repos: repos, adminToken: adminToken, userToken: userToken}
+}
+
+// do выполняет запрос к серверу и возвращает статус и тело ответа
+func (env *testEnv) do(t *testing.T, method, path, token string, body interface{}) (int, []byte) {
+	t.Helper()
+
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", token)
+	}
+	resp, data := env.doWithHeader(t, method, path, header, body)
+	return resp.StatusCode, data
+}
+
+// doWithHeader выполняет запрос с заданными заголовками и возвращает ответ с прочитанным телом
+func (env *testEnv) doWithHeader(t *testing.T, method, path string, header http.Header, body interface{}) (*http.Response, []byte) {
+	t.Helper()
+
+	// Тело []byte отправляется как есть, остальные значения кодируются в JSON
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, env.server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := env.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, data
+}
+
+// login входит под именем пользователя и возвращает выданный токен
+func (env *testEnv) login(t *testing.T, username, password string) string {
+	t.Helper()
+
+	status, data := env.do(t, http.MethodPost, "/login", "", map[string]string{"username": username, "password": password})
+	if status != http.StatusOK {
+		t.Fatalf("POST /login для %s: ожидался статус 200, получен %d: %s", username, status, data)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token
+}
+
+// createBanner создает баннер от имени администратора и возвращает его ID
+func (env *testEnv) createBanner(t *testing.T, body map[string]interface{}) int {
+	t.Helper()
+
+	status, data := env.do(t, http.MethodPost, "/banner", env.adminToken, body)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /banner: ожидался статус 201, получен %d: %s", status, data)
+	}
+
+	var created struct {
+		BannerID int `json:"banner_id"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.BannerID == 0 {
+		t.Fatalf("POST /banner: в ответе нет banner_id: %s", data)
+	}
+
+	return created.BannerID
+}
+
+// userBannerContent возвращает содержимое баннера пользователя из ответа /user_banner
+func userBannerContent(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+
+	var banner struct {
+		JSONStructure string `json:"json_structure"`
+	}
+	if err := json.Unmarshal(data, &banner); err != nil {
+		t.Fatal(err)
+	}
+
+	content := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(banner.JSONStructure), &content); err != nil {
+		t.Fatalf("некорректное содержимое баннера %q: %v", banner.JSONStructure, err)
+	}
+
+	return content
+}
+
+func TestBannerCRUD(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		id := env.createBanner(t, map[string]interface{}{
+			"tag_ids":    []int{1, 2},
+			"feature_id": 10,
+			"content":    map[string]interface{}{"title": "first"},
+			"is_active":  true,
+		})
+
+		status, data := env.do(t, http.MethodGet, "/banner?feature_id=10&limit=10", env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /banner: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var list struct {
+			Items []struct {
+				BannerID int   `json:"banner_id"`
+				TagIDs   []int `json:"tag_ids"`
+			} `json:"items"`
+			Total int `json:"total"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			t.Fatal(err)
+		}
+		if list.Total != 1 || len(list.Items) != 1 || list.Items[0].BannerID != id || len(list.Items[0].TagIDs) != 2 {
+			t.Fatalf("GET /banner: неожиданный список баннеров: %s", data)
+		}
+
+		status, data = env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(id), env.adminToken, map[string]interface{}{
+			"tag_ids":    []int{1},
+			"feature_id": 10,
+			"content":    map[string]interface{}{"title": "second"},
+			"is_active":  true,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", id, status, data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=10&use_last_revision=true", env.userToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /user_banner: ожидался статус 200, получен %d: %s", status, data)
+		}
+		if title := userBannerContent(t, data)["title"]; title != "second" {
+			t.Fatalf("GET /user_banner: ожидался обновленный баннер, получен title=%v", title)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/user_banner?tag_id=2&feature_id=10&use_last_revision=true", env.userToken, nil)
+		if status != http.StatusNotFound {
+			t.Fatalf("GET /user_banner по удаленному тегу: ожидался статус 404, получен %d: %s", status, data)
+		}
+
+		status, data = env.do(t, http.MethodDelete, "/banner/"+strconv.Itoa(id), env.adminToken, nil)
+		if status != http.StatusNoContent {
+			t.Fatalf("DELETE /banner/%d: ожидался статус 204, получен %d: %s", id, status, data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=10&use_last_revision=true", env.userToken, nil)
+		if status != http.StatusNotFound {
+			t.Fatalf("GET /user_banner после удаления: ожидался статус 404, получен %d: %s", status, data)
+		}
+	})
+}
+
+// bannerList представляет ответ GET /banner
+type bannerList struct {
+	Items []struct {
+		BannerID int `json:"banner_id"`
+	} `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// listAllBanners проходит список баннеров по курсорам и возвращает ID в порядке выдачи
+func (env *testEnv) listAllBanners(t *testing.T, query string) ([]int, int) {
+	t.Helper()
+
+	var ids []int
+	total := -1
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("GET /banner: курсоры не сходятся")
+		}
+
+		path := "/banner?limit=2&" + query
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		status, data := env.do(t, http.MethodGet, path, env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s: ожидался статус 200, получен %d: %s", path, status, data)
+		}
+
+		var page bannerList
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatal(err)
+		}
+		if total == -1 {
+			total = page.Total
+		}
+		for _, item := range page.Items {
+			ids = append(ids, item.BannerID)
+		}
+
+		if page.NextCursor == "" {
+			return ids, total
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func TestBannerListPagination(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		var ids []int
+		for i := 0; i < 5; i++ {
+			ids = append(ids, env.createBanner(t, map[string]interface{}{
+				"tag_ids": []int{1}, "feature_id": 7, "is_active": true,
+				"content": map[string]interface{}{"n": i},
+			}))
+		}
+
+		// Обновленный первым баннер становится последним по updated_at
+		status, data := env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(ids[0]), env.adminToken, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 7, "is_active": true,
+			"content": map[string]interface{}{"n": "updated"},
+		})
+		if status != http.StatusOK {
+			t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", ids[0], status, data)
+		}
+
+		tests := []struct {
+			query string
+			want  []int
+		}{
+			{"feature_id=7", ids},
+			{"feature_id=7&order=desc", []int{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+			{"feature_id=7&sort=created_at", ids},
+			{"feature_id=7&sort=updated_at&order=desc", []int{ids[0], ids[4], ids[3], ids[2], ids[1]}},
+		}
+		for _, tt := range tests {
+			got, total := env.listAllBanners(t, tt.query)
+			if total != len(ids) {
+				t.Errorf("%s: ожидался total=%d, получен %d", tt.query, len(ids), total)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("%s: ожидались баннеры %v, получены %v", tt.query, tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("%s: ожидались баннеры %v, получены %v", tt.query, tt.want, got)
+				}
+			}
+		}
+
+		// Курсор, выданный для другой сортировки, отклоняется
+		_, data = env.do(t, http.MethodGet, "/banner?limit=2&feature_id=7", env.adminToken, nil)
+		var page bannerList
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Fatal(err)
+		}
+		status, data = env.do(t, http.MethodGet, "/banner?limit=2&feature_id=7&order=desc&cursor="+page.NextCursor, env.adminToken, nil)
+		if status != http.StatusBadRequest {
+			t.Fatalf("курсор другой сортировки: ожидался статус 400, получен %d: %s", status, data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/banner?limit=2&sort=name", env.adminToken, nil)
+		if status != http.StatusBadRequest {
+			t.Fatalf("неизвестная сортировка: ожидался статус 400, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestBannerSearch(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		sale := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "Большая распродажа", "text": "Скидки на электронику", "url": "https://avito.ru/sale"},
+		})
+		cars := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "Автомобили", "text": "Большой выбор машин", "url": "https://autoteka.ru"},
+		})
+
+		tests := []struct {
+			query  string
+			status int
+			want   []int
+		}{
+			{"q=распродажа", http.StatusOK, []int{sale}},
+			{"q=большой+выбор", http.StatusOK, []int{cars}},
+			{"q=скидки+машин", http.StatusOK, nil},
+			{"filter=content.url+contains+AVITO.RU", http.StatusOK, []int{sale}},
+			{"filter=content.title+%3D+Автомобили", http.StatusOK, []int{cars}},
+			{"filter=content.url+contains+.ru&filter=content.text+contains+электроник", http.StatusOK, []int{sale}},
+			{"q=распродажа&filter=content.url+contains+autoteka", http.StatusOK, nil},
+			{"filter=content.url+like+avito", http.StatusBadRequest, nil},
+			{"filter=url+contains+avito", http.StatusBadRequest, nil},
+		}
+
+		for _, tt := range tests {
+			status, data := env.do(t, http.MethodGet, "/banner?limit=10&"+tt.query, env.adminToken, nil)
+			if status != tt.status {
+				t.Fatalf("%s: ожидался статус %d, получен %d: %s", tt.query, tt.status, status, data)
+			}
+			if status != http.StatusOK {
+				continue
+			}
+
+			var page bannerList
+			if err := json.Unmarshal(data, &page); err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, item := range page.Items {
+				got = append(got, item.BannerID)
+			}
+			if len(got) != len(tt.want) || page.Total != len(tt.want) {
+				t.Fatalf("%s: ожидались баннеры %v, получены %v (total=%d)", tt.query, tt.want, got, page.Total)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("%s: ожидались баннеры %v, получены %v", tt.query, tt.want, got)
+				}
+			}
+		}
+	})
+}
+
+func TestBannerStats(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		id := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1, 2}, "feature_id": 7, "is_active": true,
+			"content": map[string]interface{}{"title": "stats"},
+		})
+
+		// Баннер сменил фичу в течение дня, поэтому строки тегов относятся к разным фичам
+		now := time.Now().UTC()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		err := env.repos.stats.IncrementBannerStats(context.Background(), []*entity.BannerStat{
+			{Date: day, BannerID: id, TagID: 1, FeatureID: 7, Impressions: 10, Clicks: 1},
+			{Date: day, BannerID: id, TagID: 2, FeatureID: 7, Impressions: 30, Clicks: 3},
+			{Date: day, BannerID: id, TagID: 3, FeatureID: 8, Impressions: 10, Clicks: 6},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			groupBy string
+			want    []entity.BannerStat
+		}{
+			{"", []entity.BannerStat{
+				{TagID: 1, FeatureID: 7, Impressions: 10, Clicks: 1},
+				{TagID: 2, FeatureID: 7, Impressions: 30, Clicks: 3},
+				{TagID: 3, FeatureID: 8, Impressions: 10, Clicks: 6},
+			}},
+			{"feature", []entity.BannerStat{
+				{FeatureID: 7, Impressions: 40, Clicks: 4},
+				{FeatureID: 8, Impressions: 10, Clicks: 6},
+			}},
+			{"banner", []entity.BannerStat{
+				{Impressions: 50, Clicks: 10},
+			}},
+		}
+
+		for _, tt := range tests {
+			t.Run("group by "+tt.groupBy, func(t *testing.T) {
+				path := "/banner/" + strconv.Itoa(id) + "/stats"
+				if tt.groupBy != "" {
+					path += "?group_by=" + tt.groupBy
+				}
+				status, data := env.do(t, http.MethodGet, path, env.adminToken, nil)
+				if status != http.StatusOK {
+					t.Fatalf("ожидался статус 200, получен %d: %s", status, data)
+				}
+
+				var stats []entity.BannerStat
+				if err := json.Unmarshal(data, &stats); err != nil {
+					t.Fatal(err)
+				}
+				if len(stats) != len(tt.want) {
+					t.Fatalf("ожидалось %d строк, получено %d: %s", len(tt.want), len(stats), data)
+				}
+				for i, want := range tt.want {
+					got := stats[i]
+					wantCTR := float64(want.Clicks) / float64(want.Impressions)
+					if got.BannerID != id || got.TagID != want.TagID || got.FeatureID != want.FeatureID ||
+						got.Impressions != want.Impressions || got.Clicks != want.Clicks || got.CTR != wantCTR {
+						t.Errorf("строка %d: ожидалось %+v с CTR %v, получено %+v", i, want, wantCTR, got)
+					}
+				}
+			})
+		}
+
+		status, data := env.do(t, http.MethodGet, "/banner/"+strconv.Itoa(id)+"/stats?group_by=day", env.adminToken, nil)
+		if status != http.StatusBadRequest {
+			t.Fatalf("неизвестная группировка: ожидался статус 400, получен %d: %s", status, data)
+		}
+
+		clicks := []struct {
+			name   string
+			path   string
+			status int
+		}{
+			{"with tag", "/banner/" + strconv.Itoa(id) + "/click?tag_id=1", http.StatusNoContent},
+			{"without tag", "/banner/" + strconv.Itoa(id) + "/click", http.StatusBadRequest},
+			{"zero tag", "/banner/" + strconv.Itoa(id) + "/click?tag_id=0", http.StatusBadRequest},
+			{"foreign tag", "/banner/" + strconv.Itoa(id) + "/click?tag_id=3", http.StatusBadRequest},
+			{"unknown banner", "/banner/999999/click?tag_id=1", http.StatusNotFound},
+		}
+		for _, tt := range clicks {
+			t.Run("click "+tt.name, func(t *testing.T) {
+				status, data := env.do(t, http.MethodPost, tt.path, env.userToken, nil)
+				if status != tt.status {
+					t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, status, data)
+				}
+			})
+		}
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		start := time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+
+		id := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "audit"},
+		})
+		status, data := env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(id), env.adminToken, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": false,
+			"content": map[string]interface{}{"title": "audit"},
+		})
+		if status != http.StatusOK {
+			t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", id, status, data)
+		}
+		status, data = env.do(t, http.MethodDelete, "/banner/"+strconv.Itoa(id), env.adminToken, nil)
+		if status != http.StatusNoContent {
+			t.Fatalf("DELETE /banner/%d: ожидался статус 204, получен %d: %s", id, status, data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/audit?entity=banner&actor_id=1&from="+start, env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /audit: ожидался статус 200, получен %d: %s", status, data)
+		}
+
+		var records []struct {
+			ActorID  int    `json:"actor_id"`
+			Action   string `json:"action"`
+			Entity   string `json:"entity"`
+			EntityID int    `json:"entity_id"`
+			Diff     map[string]struct {
+				Before interface{} `json:"before"`
+				After  interface{} `json:"after"`
+			} `json:"diff"`
+		}
+		if err := json.Unmarshal(data, &records); err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("GET /audit: ожидалось 3 записи, получено %d: %s", len(records), data)
+		}
+
+		// Записи идут от последних к первым
+		for i, action := range []string{"delete", "update", "create"} {
+			if records[i].Action != action || records[i].Entity != "banner" || records[i].EntityID != id || records[i].ActorID != 1 {
+				t.Fatalf("запись %d: ожидалось действие %q над баннером %d, получено %+v", i, action, id, records[i])
+			}
+		}
+		isActive, ok := records[1].Diff["is_active"]
+		if !ok || isActive.Before != true || isActive.After != false {
+			t.Fatalf("изменение is_active не попало в журнал: %s", data)
+		}
+		if _, ok := records[1].Diff["json_structure"]; ok {
+			t.Fatalf("неизменившееся содержимое попало в журнал: %s", data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/audit?actor_id=2", env.adminToken, nil)
+		if status != http.StatusOK || string(data) != "[]" {
+			t.Fatalf("GET /audit по другому автору: ожидался пустой список, получен %d: %s", status, data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/audit", env.userToken, nil)
+		if status != http.StatusForbidden {
+			t.Fatalf("GET /audit с токеном пользователя: ожидался статус 403, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestBannerOptimisticLocking(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		body := map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 4, "is_active": true,
+			"content": map[string]interface{}{"title": "versioned"},
+		}
+		header := http.Header{"Authorization": {env.adminToken}}
+		resp, data := env.doWithHeader(t, http.MethodPost, "/banner", header, body)
+		if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") != `"1"` {
+			t.Fatalf("POST /banner: ожидался статус 201 с ETag \"1\", получен %d с %q: %s", resp.StatusCode, resp.Header.Get("ETag"), data)
+		}
+		var created entity.Banner
+		if err := json.Unmarshal(data, &created); err != nil {
+			t.Fatal(err)
+		}
+		path := "/banner/" + strconv.Itoa(created.ID)
+
+		// patch обновляет баннер с заданным If-Match и возвращает статус и новый ETag
+		patch := func(ifMatch string) (int, string) {
+			t.Helper()
+			header := http.Header{"Authorization": {env.adminToken}}
+			if ifMatch != "" {
+				header.Set("If-Match", ifMatch)
+			}
+			resp, _ := env.doWithHeader(t, http.MethodPatch, path, header, body)
+			return resp.StatusCode, resp.Header.Get("ETag")
+		}
+
+		checks := []struct {
+			name    string
+			ifMatch string
+			status  int
+			etag    string
+		}{
+			{"актуальная версия", `"1"`, http.StatusOK, `"2"`},
+			{"устаревшая версия", `"1"`, http.StatusPreconditionFailed, ""},
+			{"слабый ETag", `W/"2"`, http.StatusPreconditionFailed, ""},
+			{"любая версия", "*", http.StatusOK, `"3"`},
+			{"без If-Match", "", http.StatusOK, `"4"`},
+			{"список версий", `"1", W/"4", "4"`, http.StatusOK, `"5"`},
+			{"список устаревших версий", `"1", "2"`, http.StatusPreconditionFailed, ""},
+		}
+		for _, check := range checks {
+			if status, etag := patch(check.ifMatch); status != check.status || etag != check.etag {
+				t.Fatalf("%s: ожидался статус %d с ETag %q, получен %d с %q", check.name, check.status, check.etag, status, etag)
+			}
+		}
+
+		stored, err := env.repos.banners.GetBannerByID(context.Background(), created.ID, false)
+		if err != nil || stored.Version != 5 {
+			t.Fatalf("ожидалась сохраненная версия 5, получено %+v, %v", stored, err)
+		}
+
+		// Изменение, сделанное между чтением и записью, отклоняет сам репозиторий
+		stored.Version = 4
+		if err := env.repos.banners.UpdateBanner(context.Background(), stored); !errors.Is(err, entity.ErrVersionConflict) {
+			t.Fatalf("UpdateBanner с устаревшей версией: ожидалась ErrVersionConflict, получено %v", err)
+		}
+	})
+
+	// С RequireIfMatch изменение без If-Match отклоняется, а "*" явно разрешает изменить любую версию
+	env := newTestEnvWithConfig(t, newMemoryStorage(), api.RouterConfig{RequestTimeout: 5 * time.Second, RequireIfMatch: true})
+	body := map[string]interface{}{
+		"tag_ids": []int{1}, "feature_id": 4, "is_active": true,
+		"content": map[string]interface{}{"title": "required"},
+	}
+	path := "/banner/" + strconv.Itoa(env.createBanner(t, body))
+	resp, data := env.doWithHeader(t, http.MethodPatch, path, http.Header{"Authorization": {env.adminToken}}, body)
+	if resp.StatusCode != http.StatusPreconditionRequired || !strings.Contains(string(data), api.CodePreconditionRequired) {
+		t.Fatalf("PATCH без If-Match: ожидался статус 428, получен %d: %s", resp.StatusCode, data)
+	}
+	resp, data = env.doWithHeader(t, http.MethodPatch, path, http.Header{"Authorization": {env.adminToken}, "If-Match": {"*"}}, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH с If-Match *: ожидался статус 200, получен %d: %s", resp.StatusCode, data)
+	}
+}
+
+func TestBannerVersionMigration(t *testing.T) {
+	ctx := context.Background()
+	database := openDisposableSchema(t, postgresDSN(t))
+	repo := db.NewBannerRepository(database)
+
+	banner := &entity.Banner{JSONStructure: `{"title":"legacy"}`, FeatureID: 4, TagIDs: []int{1}, IsActive: true}
+	if err := repo.CreateBanner(ctx, banner); err != nil {
+		t.Fatal(err)
+	}
+	// Баннеры из схемы без столбца version после SetupTables получают версию 1 и обновляются условно
+	if _, err := database.Exec("ALTER TABLE banners DROP COLUMN version"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.NewDBManager(database).SetupTables(); err != nil {
+		t.Fatalf("повторный SetupTables: %v", err)
+	}
+
+	stored, err := repo.GetBannerByID(ctx, banner.ID, false)
+	if err != nil || stored.Version != 1 {
+		t.Fatalf("ожидалась версия 1 после миграции, получено %+v, %v", stored, err)
+	}
+	if err := repo.UpdateBanner(ctx, stored); err != nil {
+		t.Fatalf("UpdateBanner с актуальной версией: %v", err)
+	}
+	if stored.Version != 2 {
+		t.Fatalf("ожидалась версия 2 после обновления, получена %d", stored.Version)
+	}
+	stored.Version = 1
+	if err := repo.UpdateBanner(ctx, stored); !errors.Is(err, entity.ErrVersionConflict) {
+		t.Fatalf("UpdateBanner с устаревшей версией: ожидалась ErrVersionConflict, получено %v", err)
+	}
+}
+
+func TestBannerSoftDelete(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		body := map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 3, "is_active": true,
+			"content": map[string]interface{}{"title": "campaign"},
+		}
+		id := env.createBanner(t, body)
+		path := "/banner/" + strconv.Itoa(id)
+
+		status, data := env.do(t, http.MethodDelete, path, env.adminToken, nil)
+		if status != http.StatusNoContent {
+			t.Fatalf("DELETE %s: ожидался статус 204, получен %d: %s", path, status, data)
+		}
+
+		// Удаленный баннер не виден ни в одном чтении
+		checks := []struct {
+			method string
+			path   string
+			body   interface{}
+			status int
+		}{
+			{http.MethodGet, "/user_banner?tag_id=1&feature_id=3&use_last_revision=true", nil, http.StatusNotFound},
+			{http.MethodPatch, path, body, http.StatusNotFound},
+			{http.MethodDelete, path, nil, http.StatusNotFound},
+		}
+		for _, check := range checks {
+			if status, data := env.do(t, check.method, check.path, env.adminToken, check.body); status != check.status {
+				t.Fatalf("%s %s после удаления: ожидался статус %d, получен %d: %s", check.method, check.path, check.status, status, data)
+			}
+		}
+		if ids, total := env.listAllBanners(t, "feature_id=3"); len(ids) != 0 || total != 0 {
+			t.Fatalf("GET /banner после удаления: ожидался пустой список, получены %v (total=%d)", ids, total)
+		}
+
+		status, data = env.do(t, http.MethodPost, path+"/restore", env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("POST %s/restore: ожидался статус 200, получен %d: %s", path, status, data)
+		}
+		status, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=3&use_last_revision=true", env.userToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /user_banner после восстановления: ожидался статус 200, получен %d: %s", status, data)
+		}
+		status, data = env.do(t, http.MethodPost, path+"/restore", env.adminToken, nil)
+		if status != http.StatusNotFound {
+			t.Fatalf("повторное восстановление: ожидался статус 404, получен %d: %s", status, data)
+		}
+
+		// После окончательного удаления восстановить баннер нельзя
+		env.do(t, http.MethodDelete, path, env.adminToken, nil)
+		retention := usecase.NewBannerRetention(env.repos.banners, time.Hour, time.Minute)
+		if purged, err := retention.Purge(context.Background(), time.Now()); err != nil || purged != 0 {
+			t.Fatalf("баннер удален раньше срока хранения: purged=%d, err=%v", purged, err)
+		}
+		if purged, err := retention.Purge(context.Background(), time.Now().Add(2*time.Hour)); err != nil || purged != 1 {
+			t.Fatalf("ожидалось окончательное удаление одного баннера: purged=%d, err=%v", purged, err)
+		}
+		status, data = env.do(t, http.MethodPost, path+"/restore", env.adminToken, nil)
+		if status != http.StatusNotFound {
+			t.Fatalf("восстановление после окончательного удаления: ожидался статус 404, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestBannerImportExport(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		first := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1, 2}, "feature_id": 5, "is_active": true,
+			"content": map[string]interface{}{"title": "first"},
+		})
+		env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{3}, "feature_id": 5, "is_active": false,
+			"content": map[string]interface{}{"title": "second, with comma"},
+		})
+
+		for _, format := range []string{"ndjson", "csv"} {
+			status, exported := env.do(t, http.MethodGet, "/banner/export?format="+format, env.adminToken, nil)
+			if status != http.StatusOK {
+				t.Fatalf("GET /banner/export?format=%s: ожидался статус 200, получен %d: %s", format, status, exported)
+			}
+
+			// Повторный импорт выгрузки обновляет те же баннеры, а не создает новые
+			status, data := env.do(t, http.MethodPost, "/banner/import?format="+format, env.adminToken, exported)
+			if status != http.StatusOK {
+				t.Fatalf("POST /banner/import?format=%s: ожидался статус 200, получен %d: %s", format, status, data)
+			}
+			var report entity.BannerImportReport
+			if err := json.Unmarshal(data, &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Created != 0 || report.Updated != 2 {
+				t.Fatalf("импорт выгрузки %s: ожидалось 0 созданных и 2 обновленных, получено %+v", format, report)
+			}
+		}
+		if _, total := env.listAllBanners(t, "feature_id=5"); total != 2 {
+			t.Fatalf("после повторного импорта ожидалось 2 баннера, получено %d", total)
+		}
+
+		changed := []byte(`{"feature_id":5,"tag_ids":[2,1],"content":{"title":"changed"},"is_active":true}
+{"feature_id":6,"tag_ids":[1],"content":{"title":"new"},"is_active":true}
+`)
+		status, data := env.do(t, http.MethodPost, "/banner/import?dry_run=true", env.adminToken, changed)
+		if status != http.StatusOK {
+			t.Fatalf("пробный импорт: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var report entity.BannerImportReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if !report.DryRun || report.Created != 1 || report.Updated != 1 {
+			t.Fatalf("пробный импорт: ожидались 1 созданный и 1 обновленный баннер, получено %+v", report)
+		}
+		if _, total := env.listAllBanners(t, "feature_id=6"); total != 0 {
+			t.Fatalf("пробный импорт сохранил баннер")
+		}
+
+		status, data = env.do(t, http.MethodPost, "/banner/import", env.adminToken, changed)
+		if status != http.StatusOK {
+			t.Fatalf("импорт: ожидался статус 200, получен %d: %s", status, data)
+		}
+		status, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=5&use_last_revision=true", env.userToken, nil)
+		if status != http.StatusOK || userBannerContent(t, data)["title"] != "changed" {
+			t.Fatalf("после импорта баннер %d не обновлен: %d %s", first, status, data)
+		}
+
+		// Ошибка в любой строке отменяет импорт целиком
+		broken := []byte(`{"feature_id":7,"tag_ids":[1],"content":{"title":"ok"}}
+{"feature_id":7,"tag_ids":[],"content":{"title":"no tags"}}
+not json
+`)
+		status, data = env.do(t, http.MethodPost, "/banner/import", env.adminToken, broken)
+		if status != http.StatusUnprocessableEntity {
+			t.Fatalf("импорт с ошибками: ожидался статус 422, получен %d: %s", status, data)
+		}
+		report = entity.BannerImportReport{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Errors) != 2 || report.Errors[0].Line != 2 || report.Errors[1].Line != 3 {
+			t.Fatalf("ожидались ошибки в строках 2 и 3, получено %+v", report.Errors)
+		}
+		if _, total := env.listAllBanners(t, "feature_id=7"); total != 0 {
+			t.Fatalf("импорт с ошибками сохранил баннер")
+		}
+
+		if status, data := env.do(t, http.MethodGet, "/banner/export", env.userToken, nil); status != http.StatusForbidden {
+			t.Fatalf("GET /banner/export с токеном пользователя: ожидался статус 403, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestBannerImportTargetAmongManyBanners(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		ctx := context.Background()
+
+		// Баннеры с тегом 1 и другими тегами создаются раньше, поэтому искомый баннер оказывается последним по ID
+		for tagID := 2; tagID <= 150; tagID++ {
+			banner := &entity.Banner{JSONStructure: `{"title":"other"}`, FeatureID: 9, TagIDs: []int{1, tagID}, IsActive: true}
+			if err := env.repos.banners.CreateBanner(ctx, banner); err != nil {
+				t.Fatal(err)
+			}
+		}
+		target := &entity.Banner{JSONStructure: `{"title":"target"}`, FeatureID: 9, TagIDs: []int{1}, IsActive: true}
+		if err := env.repos.banners.CreateBanner(ctx, target); err != nil {
+			t.Fatal(err)
+		}
+
+		status, data := env.do(t, http.MethodPost, "/banner/import", env.adminToken,
+			[]byte(`{"feature_id":9,"tag_ids":[1],"content":{"title":"imported"},"is_active":true}`+"\n"))
+		if status != http.StatusOK {
+			t.Fatalf("импорт: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var report entity.BannerImportReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Created != 0 || report.Updated != 1 {
+			t.Fatalf("ожидалось обновление существующего баннера, получено %+v", report)
+		}
+
+		banner, err := env.repos.banners.GetBannerByID(ctx, target.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if banner.JSONStructure != `{"title":"imported"}` {
+			t.Fatalf("баннер %d не обновлен импортом: %s", target.ID, banner.JSONStructure)
+		}
+	})
+}
+
+func TestBannerTransactionRollbackKeepsConcurrentWrites(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		ctx := context.Background()
+		errRollback := errors.New("откат")
+
+		concurrent := &entity.Banner{JSONStructure: `{"title":"concurrent"}`, FeatureID: 1, TagIDs: []int{1}, IsActive: true}
+		written := make(chan error, 1)
+		var inTx *entity.Banner
+
+		err := env.repos.banners.InTransaction(ctx, func(txCtx context.Context) error {
+			inTx = &entity.Banner{JSONStructure: `{"title":"rolled back"}`, FeatureID: 2, TagIDs: []int{2}, IsActive: true}
+			if err := env.repos.banners.CreateBanner(txCtx, inTx); err != nil {
+				return err
+			}
+
+			// Параллельная запись идет мимо транзакции и должна пережить ее откат
+			go func() { written <- env.repos.banners.CreateBanner(ctx, concurrent) }()
+			time.Sleep(50 * time.Millisecond)
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("ожидалась ошибка транзакции, получено %v", err)
+		}
+		if err := <-written; err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := env.repos.banners.GetBannerByID(ctx, concurrent.ID, false); err != nil {
+			t.Fatalf("откат транзакции удалил параллельно созданный баннер: %v", err)
+		}
+		if _, err := env.repos.banners.GetBannerByID(ctx, inTx.ID, false); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("баннер из откатанной транзакции сохранился: %v", err)
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	env := newTestEnvWithConfig(t, newMemoryStorage(), api.RouterConfig{
+		RequestTimeout: 5 * time.Second,
+		RateLimits: map[string]api.RateLimit{
+			api.RateLimitGroupUserBanner: {RequestsPerSecond: 0.01, Burst: 3},
+			api.RateLimitGroupLogin:      {RequestsPerSecond: 0.01, Burst: 1},
+			api.RateLimitGroupAPIKey:     {RequestsPerSecond: 0.01, Burst: 2},
+		},
+	})
+
+	// Проверка API ключей ограничена по IP до обращения к хранилищу, даже для несуществующих ключей
+	for i := 0; i < 2; i++ {
+		header := http.Header{api.APIKeyHeader: {entity.APIKeyPrefix + strconv.Itoa(i)}}
+		if resp, data := env.doWithHeader(t, http.MethodGet, "/banner?limit=10", header, nil); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("неверный ключ %d: ожидался статус 401, получен %d: %s", i+1, resp.StatusCode, data)
+		}
+	}
+	resp, data := env.doWithHeader(t, http.MethodGet, "/banner?limit=10", http.Header{api.APIKeyHeader: {entity.APIKeyPrefix + "x"}}, nil)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("неверный ключ сверх лимита IP: ожидался статус 429 с Retry-After, получен %d: %s", resp.StatusCode, data)
+	}
+
+	// Запросы с токеном учитываются по пользователю: исчерпанный лимит одного не мешает другому
+	path := "/user_banner?tag_id=1&feature_id=1"
+	for i := 0; i < 3; i++ {
+		if status, data := env.do(t, http.MethodGet, path, env.userToken, nil); status != http.StatusNotFound {
+			t.Fatalf("запрос %d: ожидался статус 404, получен %d: %s", i+1, status, data)
+		}
+	}
+	if status, data := env.do(t, http.MethodGet, path, env.userToken, nil); status != http.StatusTooManyRequests {
+		t.Fatalf("сверх лимита: ожидался статус 429, получен %d: %s", status, data)
+	}
+	if status, data := env.do(t, http.MethodGet, path, env.adminToken, nil); status != http.StatusNotFound {
+		t.Fatalf("другой пользователь: ожидался статус 404, получен %d: %s", status, data)
+	}
+
+	// Поддельный токен не дает нового ключа: запрос учитывается по IP
+	for i := 0; i < 3; i++ {
+		env.do(t, http.MethodGet, path, "forged", nil)
+	}
+	if status, data := env.do(t, http.MethodGet, path, "forged-again", nil); status != http.StatusTooManyRequests {
+		t.Fatalf("поддельный токен сверх лимита IP: ожидался статус 429, получен %d: %s", status, data)
+	}
+
+	credentials := map[string]string{"username": "nobody", "password": "wrong"}
+	if status, data := env.do(t, http.MethodPost, "/login", "", credentials); status != http.StatusUnauthorized {
+		t.Fatalf("POST /login: ожидался статус 401, получен %d: %s", status, data)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, env.server.URL+"/login", strings.NewReader(`{"username":"nobody","password":"wrong"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = env.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("повторный POST /login: ожидался статус 429, получен %d", resp.StatusCode)
+	}
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retryAfter < 1 {
+		t.Fatalf("ожидался заголовок Retry-After в секундах, получен %q", resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestUserPolicyAndLockout(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		users := usecase.NewUserUseCase(env.repos.users, env.repos.roles, env.repos.grants, auth.NewTokenService([]byte("test-secret")), usecase.DefaultUserPolicy(), nil)
+		ctx := context.Background()
+
+		registrations := []struct {
+			name     string
+			username string
+			password string
+			role     string
+			token    string
+			err      error
+		}{
+			{"пустой пароль", "alice", "", "", "", usecase.ErrInvalidParams},
+			{"короткий пароль", "alice", "abc123", "", "", usecase.ErrInvalidParams},
+			{"пароль без цифр", "alice", "password", "", "", usecase.ErrInvalidParams},
+			{"пароль совпадает с именем", "alice2024", "Alice2024", "", "", usecase.ErrInvalidParams},
+			{"неизвестная роль", "alice", "correct1horse", "superuser", "", usecase.ErrInvalidParams},
+			{"роль admin без токена", "alice", "correct1horse", "admin", "", usecase.ErrUnauthorized},
+			{"роль admin от пользователя", "alice", "correct1horse", "admin", env.userToken, usecase.ErrForbidden},
+			{"роль admin от администратора", "root", "correct1horse", "admin", env.adminToken, nil},
+			{"обычный пользователь", "alice", "correct1horse", "", "", nil},
+		}
+		for _, r := range registrations {
+			if _, err := users.RegisterUser(ctx, r.username, r.password, r.role, r.token); !errors.Is(err, r.err) {
+				t.Fatalf("%s: ожидалась ошибка %v, получена %v", r.name, r.err, err)
+			}
+		}
+
+		login := func(password string) (int, []byte) {
+			return env.do(t, http.MethodPost, "/login", "", map[string]string{"username": "alice", "password": password})
+		}
+		if status, data := login("correct1horse"); status != http.StatusOK {
+			t.Fatalf("POST /login: ожидался статус 200, получен %d: %s", status, data)
+		}
+
+		// Пятая неудачная попытка подряд блокирует вход, и верный пароль больше не принимается
+		policy := usecase.DefaultUserPolicy()
+		for i := 1; i < policy.MaxFailedLogins; i++ {
+			if status, data := login("wrong1password"); status != http.StatusUnauthorized {
+				t.Fatalf("неудачная попытка %d: ожидался статус 401, получен %d: %s", i, status, data)
+			}
+		}
+		if status, data := login("wrong1password"); status != http.StatusTooManyRequests {
+			t.Fatalf("попытка, после которой вход блокируется: ожидался статус 429, получен %d: %s", status, data)
+		}
+		if status, data := login("correct1horse"); status != http.StatusTooManyRequests {
+			t.Fatalf("верный пароль во время блокировки: ожидался статус 429, получен %d: %s", status, data)
+		}
+
+		user, err := env.repos.users.GetUserByUsername(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.LockedUntil == nil || time.Until(*user.LockedUntil) > policy.LockoutBase {
+			t.Fatalf("ожидалась блокировка не дольше %s, получено %v", policy.LockoutBase, user.LockedUntil)
+		}
+
+		// После окончания блокировки следующая неудача блокирует вход вдвое дольше
+		if err := env.repos.users.LockUser(ctx, user.ID, time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		login("wrong1password")
+		user, err = env.repos.users.GetUserByUsername(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remaining := time.Until(*user.LockedUntil); remaining <= policy.LockoutBase || remaining > 2*policy.LockoutBase {
+			t.Fatalf("ожидалась блокировка на %s, осталось %s", 2*policy.LockoutBase, remaining)
+		}
+
+		// Несуществующее имя блокируется так же, чтобы по ответу нельзя было узнать, какие имена заняты
+		loginUnknown := func() (*http.Response, []byte) {
+			return env.doWithHeader(t, http.MethodPost, "/login", http.Header{},
+				map[string]string{"username": "nobody", "password": "wrong1password"})
+		}
+		for i := 1; i < policy.MaxFailedLogins; i++ {
+			if resp, data := loginUnknown(); resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("неизвестное имя, попытка %d: ожидался статус 401, получен %d: %s", i, resp.StatusCode, data)
+			}
+		}
+		for i := 0; i < 2; i++ {
+			resp, data := loginUnknown()
+			if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+				t.Fatalf("неизвестное имя после %d неудач: ожидался статус 429 с Retry-After, получен %d: %s",
+					policy.MaxFailedLogins+i, resp.StatusCode, data)
+			}
+			var apiErr struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(data, &apiErr); err != nil || apiErr.Code != api.CodeAccountLocked {
+				t.Fatalf("ожидался код %q, получен ответ %s", api.CodeAccountLocked, data)
+			}
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		ctx := context.Background()
+		users := usecase.NewUserUseCase(env.repos.users, env.repos.roles, env.repos.grants, auth.NewTokenService([]byte("test-secret")), usecase.DefaultUserPolicy(), nil)
+
+		// Роль сохраняется в хранилище и попадает в токен при входе
+		if _, err := users.RegisterUser(ctx, "ed", "editor1pass", entity.RoleEditor, env.adminToken); err != nil {
+			t.Fatal(err)
+		}
+		if stored, err := env.repos.users.GetUserByUsername(ctx, "ed"); err != nil || stored.Role != entity.RoleEditor {
+			t.Fatalf("ожидалась сохраненная роль %q, получено %+v, %v", entity.RoleEditor, stored, err)
+		}
+		editorToken := env.login(t, "ed", "editor1pass")
+
+		banner := map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "editor"},
+		}
+		viewer := map[string]interface{}{"permissions": []string{entity.PermissionBannerRead}}
+		checks := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   interface{}
+			status int
+		}{
+			{"редактор создает баннер", http.MethodPost, "/banner", editorToken, banner, http.StatusCreated},
+			{"редактор читает список", http.MethodGet, "/banner?limit=10", editorToken, nil, http.StatusOK},
+			{"редактор без журнала аудита", http.MethodGet, "/audit", editorToken, nil, http.StatusForbidden},
+			{"редактор без управления ролями", http.MethodPut, "/roles/viewer", editorToken, viewer, http.StatusForbidden},
+			{"роль администратора не меняется", http.MethodPut, "/roles/admin", env.adminToken, viewer, http.StatusBadRequest},
+			{"неизвестное разрешение", http.MethodPut, "/roles/viewer", env.adminToken, map[string]interface{}{"permissions": []string{"banner:fly"}}, http.StatusBadRequest},
+			{"администратор создает роль", http.MethodPut, "/roles/viewer", env.adminToken, viewer, http.StatusOK},
+		}
+		for _, check := range checks {
+			if status, data := env.do(t, check.method, check.path, check.token, check.body); status != check.status {
+				t.Fatalf("%s: ожидался статус %d, получен %d: %s", check.name, check.status, status, data)
+			}
+		}
+
+		// Собственная роль дает только свои разрешения
+		if _, err := users.RegisterUser(ctx, "vic", "viewer1pass", "viewer", env.adminToken); err != nil {
+			t.Fatal(err)
+		}
+		viewerToken := env.login(t, "vic", "viewer1pass")
+		if status, data := env.do(t, http.MethodGet, "/banner?limit=10", viewerToken, nil); status != http.StatusOK {
+			t.Fatalf("GET /banner с ролью viewer: ожидался статус 200, получен %d: %s", status, data)
+		}
+		if status, data := env.do(t, http.MethodPost, "/banner", viewerToken, banner); status != http.StatusForbidden {
+			t.Fatalf("POST /banner с ролью viewer: ожидался статус 403, получен %d: %s", status, data)
+		}
+
+		status, data := env.do(t, http.MethodGet, "/roles", env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /roles: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var roles []entity.Role
+		if err := json.Unmarshal(data, &roles); err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, len(roles))
+		for i, role := range roles {
+			names[i] = role.Name
+		}
+		if strings.Join(names, ",") != "admin,editor,user,viewer" {
+			t.Fatalf("GET /roles: ожидались роли admin,editor,user,viewer, получены %v", names)
+		}
+
+		// Управляющий пользователями выдает только роли, все разрешения которых есть у него самого
+		manager := map[string]interface{}{"permissions": []string{entity.PermissionUserManage}}
+		if status, data := env.do(t, http.MethodPut, "/roles/manager", env.adminToken, manager); status != http.StatusOK {
+			t.Fatalf("PUT /roles/manager: ожидался статус 200, получен %d: %s", status, data)
+		}
+		mia, err := users.RegisterUser(ctx, "mia", "manager1pass", "manager", env.adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		managerToken := env.login(t, "mia", "manager1pass")
+		if status, data := env.do(t, http.MethodPut, "/roles/superuser", managerToken, map[string]interface{}{"permissions": entity.Permissions}); status != http.StatusForbidden {
+			t.Fatalf("PUT /roles/superuser с чужими разрешениями: ожидался статус 403, получен %d: %s", status, data)
+		}
+		escalations := []struct {
+			name string
+			err  error
+		}{
+			{"назначение себе роли admin", func() error {
+				_, err := users.UpdateUser(ctx, mia.ID, "mia", "", entity.RoleAdmin, managerToken)
+				return err
+			}()},
+			{"назначение себе роли editor", func() error {
+				_, err := users.UpdateUser(ctx, mia.ID, "mia", "", entity.RoleEditor, managerToken)
+				return err
+			}()},
+			{"регистрация администратора", func() error {
+				_, err := users.RegisterUser(ctx, "eve", "admin1pass", entity.RoleAdmin, managerToken)
+				return err
+			}()},
+			{"смена пароля редактора", func() error {
+				stored, err := env.repos.users.GetUserByUsername(ctx, "ed")
+				if err != nil {
+					return err
+				}
+				_, err = users.UpdateUser(ctx, stored.ID, "ed", "editor2pass", entity.RoleUser, managerToken)
+				return err
+			}()},
+			{"назначение себе роли admin редактором", func() error {
+				stored, err := env.repos.users.GetUserByUsername(ctx, "ed")
+				if err != nil {
+					return err
+				}
+				_, err = users.UpdateUser(ctx, stored.ID, "ed", "", entity.RoleAdmin, editorToken)
+				return err
+			}()},
+		}
+		for _, escalation := range escalations {
+			if !errors.Is(escalation.err, usecase.ErrForbidden) {
+				t.Errorf("%s: ожидалась ErrForbidden, получено %v", escalation.name, escalation.err)
+			}
+		}
+		if _, err := users.RegisterUser(ctx, "max", "manager2pass", "manager", managerToken); err != nil {
+			t.Fatalf("регистрация с ролью manager: %v", err)
+		}
+	})
+}
+
+func TestUniqueUsername(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		ctx := context.Background()
+		users := usecase.NewUserUseCase(env.repos.users, env.repos.roles, env.repos.grants, auth.NewTokenService([]byte("test-secret")), usecase.DefaultUserPolicy(), nil)
+
+		ann, err := users.RegisterUser(ctx, "ann", "first1pass", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.RegisterUser(ctx, "ann", "second1pass", "", ""); !errors.Is(err, entity.ErrUsernameTaken) {
+			t.Fatalf("повторная регистрация: ожидалась ошибка %v, получена %v", entity.ErrUsernameTaken, err)
+		}
+
+		bob, err := users.RegisterUser(ctx, "bob", "third1pass", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.UpdateUser(ctx, bob.ID, "ann", "", entity.RoleUser, env.adminToken); !errors.Is(err, entity.ErrUsernameTaken) {
+			t.Fatalf("переименование в занятое имя: ожидалась ошибка %v, получена %v", entity.ErrUsernameTaken, err)
+		}
+		// Сохранение пользователя под его же именем не считается конфликтом
+		if _, err := users.UpdateUser(ctx, ann.ID, "ann", "", entity.RoleUser, env.adminToken); err != nil {
+			t.Fatalf("обновление без смены имени: %v", err)
+		}
+		if stored, err := env.repos.users.GetUserByUsername(ctx, "ann"); err != nil || stored.ID != ann.ID {
+			t.Fatalf("ожидался пользователь %d, получено %+v, %v", ann.ID, stored, err)
+		}
+	})
+}
+
+func TestFeatureGrants(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		ctx := context.Background()
+		users := usecase.NewUserUseCase(env.repos.users, env.repos.roles, env.repos.grants, auth.NewTokenService([]byte("test-secret")), usecase.DefaultUserPolicy(), nil)
+
+		user, err := users.RegisterUser(ctx, "fiona", "feature12pass", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := env.login(t, "fiona", "feature12pass")
+
+		banner := func(featureID int) map[string]interface{} {
+			return map[string]interface{}{
+				"tag_ids": []int{1}, "feature_id": featureID, "is_active": true,
+				"content": map[string]interface{}{"title": "grant"},
+			}
+		}
+		if status, data := env.do(t, http.MethodPost, "/banner", token, banner(12)); status != http.StatusForbidden {
+			t.Fatalf("POST /banner без разрешения: ожидался статус 403, получен %d: %s", status, data)
+		}
+
+		grant := map[string]interface{}{"user_id": user.ID, "permission": entity.PermissionBannerWrite, "feature_id": 12}
+		if status, data := env.do(t, http.MethodPost, "/grants", token, grant); status != http.StatusForbidden {
+			t.Fatalf("POST /grants без user:manage: ожидался статус 403, получен %d: %s", status, data)
+		}
+		unscoped := map[string]interface{}{"user_id": user.ID, "permission": entity.PermissionAuditRead, "feature_id": 12}
+		if status, data := env.do(t, http.MethodPost, "/grants", env.adminToken, unscoped); status != http.StatusBadRequest {
+			t.Fatalf("POST /grants с audit:read: ожидался статус 400, получен %d: %s", status, data)
+		}
+		status, data := env.do(t, http.MethodPost, "/grants", env.adminToken, grant)
+		if status != http.StatusCreated {
+			t.Fatalf("POST /grants: ожидался статус 201, получен %d: %s", status, data)
+		}
+		var created entity.PermissionGrant
+		if err := json.Unmarshal(data, &created); err != nil {
+			t.Fatal(err)
+		}
+
+		// Разрешение действует только на свою фичу, в том числе при переносе баннера между фичами
+		other := env.createBanner(t, banner(13))
+		checks := []struct {
+			name   string
+			method string
+			path   string
+			body   interface{}
+			status int
+		}{
+			{"создание в чужой фиче", http.MethodPost, "/banner", banner(13), http.StatusForbidden},
+			{"создание в своей фиче", http.MethodPost, "/banner", banner(12), http.StatusCreated},
+			{"изменение чужого баннера", http.MethodPatch, "/banner/" + strconv.Itoa(other), banner(12), http.StatusForbidden},
+			{"удаление чужого баннера", http.MethodDelete, "/banner/" + strconv.Itoa(other), nil, http.StatusForbidden},
+			// Несуществующий баннер неотличим от баннера чужой фичи
+			{"изменение несуществующего баннера", http.MethodPatch, "/banner/999999", banner(12), http.StatusForbidden},
+			{"удаление несуществующего баннера", http.MethodDelete, "/banner/999999", nil, http.StatusForbidden},
+		}
+		for _, check := range checks {
+			if status, data := env.do(t, check.method, check.path, token, check.body); status != check.status {
+				t.Fatalf("%s: ожидался статус %d, получен %d: %s", check.name, check.status, status, data)
+			}
+		}
+
+		own := env.createBanner(t, banner(12))
+		if status, data := env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(own), token, banner(13)); status != http.StatusForbidden {
+			t.Fatalf("PATCH /banner в чужую фичу: ожидался статус 403, получен %d: %s", status, data)
+		}
+		if status, data := env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(own), token, banner(12)); status != http.StatusOK {
+			t.Fatalf("PATCH /banner в своей фиче: ожидался статус 200, получен %d: %s", status, data)
+		}
+
+		// Ключ только на чтение не получает разрешений своего создателя на фичи
+		manager, err := auth.NewTokenService([]byte("test-secret")).GenerateToken(user.ID, entity.RoleUser,
+			[]string{entity.PermissionBannerRead, entity.PermissionUserManage})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, data = env.do(t, http.MethodPost, "/api_keys", manager, map[string]interface{}{
+			"name": "reader", "scopes": []string{entity.PermissionBannerRead},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("POST /api_keys: ожидался статус 201, получен %d: %s", status, data)
+		}
+		var key entity.CreatedAPIKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			t.Fatal(err)
+		}
+		header := http.Header{api.APIKeyHeader: {key.Key}}
+		if resp, data := env.doWithHeader(t, http.MethodPost, "/banner", header, banner(12)); resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("POST /banner по ключу только на чтение: ожидался статус 403, получен %d: %s", resp.StatusCode, data)
+		}
+		if resp, data := env.doWithHeader(t, http.MethodPatch, "/banner/"+strconv.Itoa(own), header, banner(12)); resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("PATCH /banner по ключу только на чтение: ожидался статус 403, получен %d: %s", resp.StatusCode, data)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/grants?user_id="+strconv.Itoa(user.ID), env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /grants: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var grants []entity.PermissionGrant
+		if err := json.Unmarshal(data, &grants); err != nil {
+			t.Fatal(err)
+		}
+		if len(grants) != 1 || grants[0].ID != created.ID || grants[0].FeatureID != 12 {
+			t.Fatalf("GET /grants: ожидалось одно разрешение на фичу 12, получено %+v", grants)
+		}
+
+		// Отзыв действует сразу, без перевыпуска токена
+		if status, data := env.do(t, http.MethodDelete, "/grants/"+strconv.Itoa(created.ID), env.adminToken, nil); status != http.StatusNoContent {
+			t.Fatalf("DELETE /grants: ожидался статус 204, получен %d: %s", status, data)
+		}
+		if status, data := env.do(t, http.MethodDelete, "/grants/"+strconv.Itoa(created.ID), env.adminToken, nil); status != http.StatusNotFound {
+			t.Fatalf("повторный DELETE /grants: ожидался статус 404, получен %d: %s", status, data)
+		}
+		if status, data := env.do(t, http.MethodDelete, "/banner/"+strconv.Itoa(own), token, nil); status != http.StatusForbidden {
+			t.Fatalf("DELETE /banner после отзыва: ожидался статус 403, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		// withKey выполняет запрос с API ключом вместо токена
+		withKey := func(method, path, key string) int {
+			t.Helper()
+			resp, _ := env.doWithHeader(t, method, path, http.Header{api.APIKeyHeader: {key}}, nil)
+			return resp.StatusCode
+		}
+
+		expired := time.Now().Add(-time.Hour)
+		invalid := []map[string]interface{}{
+			{"name": "", "scopes": []string{entity.PermissionBannerRead}},
+			{"name": "reader", "scopes": []string{"banner:fly"}},
+			{"name": "reader", "scopes": []string{entity.PermissionBannerRead}, "expires_at": expired},
+		}
+		for _, body := range invalid {
+			if status, data := env.do(t, http.MethodPost, "/api_keys", env.adminToken, body); status != http.StatusBadRequest {
+				t.Fatalf("POST /api_keys %v: ожидался статус 400, получен %d: %s", body, status, data)
+			}
+		}
+		if status, data := env.do(t, http.MethodPost, "/api_keys", env.userToken, map[string]interface{}{"name": "reader"}); status != http.StatusForbidden {
+			t.Fatalf("POST /api_keys без user:manage: ожидался статус 403, получен %d: %s", status, data)
+		}
+
+		status, data := env.do(t, http.MethodPost, "/api_keys", env.adminToken, map[string]interface{}{
+			"name": "reader", "scopes": []string{entity.PermissionBannerRead}, "expires_at": time.Now().Add(time.Hour),
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("POST /api_keys: ожидался статус 201, получен %d: %s", status, data)
+		}
+		var created entity.CreatedAPIKey
+		if err := json.Unmarshal(data, &created); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(created.Key, entity.APIKeyPrefix) || !strings.HasPrefix(created.Key, created.APIKey.Prefix) {
+			t.Fatalf("POST /api_keys: неожиданный ключ %q с началом %q", created.Key, created.APIKey.Prefix)
+		}
+		if strings.Contains(string(data), "hash") {
+			t.Fatalf("POST /api_keys: в ответе есть хеш ключа: %s", data)
+		}
+
+		// Ключ дает только свои разрешения
+		if status := withKey(http.MethodGet, "/banner?limit=10", created.Key); status != http.StatusOK {
+			t.Fatalf("GET /banner по ключу: ожидался статус 200, получен %d", status)
+		}
+		if status := withKey(http.MethodGet, "/audit", created.Key); status != http.StatusForbidden {
+			t.Fatalf("GET /audit по ключу: ожидался статус 403, получен %d", status)
+		}
+		if status := withKey(http.MethodGet, "/banner?limit=10", created.Key+"0"); status != http.StatusUnauthorized {
+			t.Fatalf("GET /banner по неверному ключу: ожидался статус 401, получен %d", status)
+		}
+
+		status, data = env.do(t, http.MethodGet, "/api_keys", env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /api_keys: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var keys []entity.APIKey
+		if err := json.Unmarshal(data, &keys); err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0].ID != created.APIKey.ID || keys[0].LastUsedAt == nil || strings.Contains(string(data), created.Key) {
+			t.Fatalf("GET /api_keys: ожидался один использованный ключ без значения, получено %s", data)
+		}
+
+		// Отзыв действует сразу
+		if status, data := env.do(t, http.MethodDelete, "/api_keys/"+strconv.Itoa(created.APIKey.ID), env.adminToken, nil); status != http.StatusNoContent {
+			t.Fatalf("DELETE /api_keys: ожидался статус 204, получен %d: %s", status, data)
+		}
+		if status := withKey(http.MethodGet, "/banner?limit=10", created.Key); status != http.StatusUnauthorized {
+			t.Fatalf("GET /banner по отозванному ключу: ожидался статус 401, получен %d", status)
+		}
+		if status, data := env.do(t, http.MethodDelete, "/api_keys/"+strconv.Itoa(created.APIKey.ID), env.adminToken, nil); status != http.StatusNotFound {
+			t.Fatalf("повторный DELETE /api_keys: ожидался статус 404, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestAPIKeyActor(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		status, data := env.do(t, http.MethodPost, "/api_keys", env.adminToken, map[string]interface{}{
+			"name": "writer", "scopes": []string{entity.PermissionBannerRead, entity.PermissionBannerWrite},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("POST /api_keys: ожидался статус 201, получен %d: %s", status, data)
+		}
+		var created entity.CreatedAPIKey
+		if err := json.Unmarshal(data, &created); err != nil {
+			t.Fatal(err)
+		}
+		keyHeader := http.Header{api.APIKeyHeader: {created.Key}}
+
+		variants := make([]map[string]interface{}, 0, 8)
+		for i := 1; i <= 8; i++ {
+			variants = append(variants, map[string]interface{}{"content": map[string]interface{}{"title": strconv.Itoa(i)}, "weight": 1})
+		}
+		resp, data := env.doWithHeader(t, http.MethodPost, "/banner", keyHeader, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content":  map[string]interface{}{"title": "base"},
+			"variants": variants,
+		})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("POST /banner по ключу: ожидался статус 201, получен %d: %s", resp.StatusCode, data)
+		}
+		var banner entity.Banner
+		if err := json.Unmarshal(data, &banner); err != nil {
+			t.Fatal(err)
+		}
+
+		// В журнале аудита действие записано на создателя ключа вместе с самим ключом
+		status, data = env.do(t, http.MethodGet, "/audit?entity=banner", env.adminToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /audit: ожидался статус 200, получен %d: %s", status, data)
+		}
+		var records []entity.AuditRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || records[0].ActorID != 1 || records[0].APIKeyID != created.APIKey.ID {
+			t.Fatalf("ожидалась запись от пользователя 1 по ключу %d, получено %s", created.APIKey.ID, data)
+		}
+
+		// Вариант содержимого закрепляется за ключом, а не за его создателем
+		resp, data = env.doWithHeader(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=1", keyHeader, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /user_banner по ключу: ожидался статус 200, получен %d: %s", resp.StatusCode, data)
+		}
+		want := banner.PickAPIKeyVariant(created.APIKey.ID)
+		if got := resp.Header.Get("X-Banner-Variant"); want == nil || got != strconv.Itoa(want.ID) {
+			t.Fatalf("ожидался вариант ключа %+v, получен %q", want, got)
+		}
+	})
+}
+
+func TestCatalogNotFound(t *testing.T) {
+	tokenService := auth.NewTokenService([]byte("test-secret"))
+	token, err := tokenService.GenerateToken(1, entity.RoleAdmin, entity.BuiltinRolePermissions(entity.RoleAdmin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(t *testing.T, features usecase.FeatureRepository, tags usecase.TagRepository) {
+		ctx := context.Background()
+		featureUseCase := usecase.NewFeatureUseCase(features, tokenService, nil)
+		tagUseCase := usecase.NewTagUseCase(tags, tokenService, nil)
+
+		if _, err := featureUseCase.UpdateFeature(ctx, 999999, "missing", token); !errors.Is(err, usecase.ErrFeatureNotFound) {
+			t.Errorf("UpdateFeature: ожидалась ErrFeatureNotFound, получено %v", err)
+		}
+		if err := featureUseCase.DeleteFeature(ctx, 999999, token); !errors.Is(err, usecase.ErrFeatureNotFound) {
+			t.Errorf("DeleteFeature: ожидалась ErrFeatureNotFound, получено %v", err)
+		}
+		if _, err := tagUseCase.UpdateTag(ctx, 999999, "missing", token); !errors.Is(err, usecase.ErrTagNotFound) {
+			t.Errorf("UpdateTag: ожидалась ErrTagNotFound, получено %v", err)
+		}
+		if err := tagUseCase.DeleteTag(ctx, 999999, token); !errors.Is(err, usecase.ErrTagNotFound) {
+			t.Errorf("DeleteTag: ожидалась ErrTagNotFound, получено %v", err)
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		run(t, memory.NewFeatureRepository(), memory.NewTagRepository())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		database := openDisposableSchema(t, postgresDSN(t))
+		run(t, db.NewFeatureRepository(database), db.NewTagRepository(database))
+	})
+}
+
+func TestCreateBannerValidation(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		status, data := env.do(t, http.MethodPost, "/banner", env.adminToken, map[string]interface{}{
+			"feature_id": 10,
+			"content":    map[string]interface{}{"title": "no tags"},
+		})
+		if status != http.StatusBadRequest {
+			t.Fatalf("POST /banner без тегов: ожидался статус 400, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestUserBannerLookup(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+
+		env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "active"},
+		})
+		env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{2}, "feature_id": 1, "is_active": false,
+			"content": map[string]interface{}{"title": "inactive"},
+		})
+		env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{3}, "feature_id": 1, "is_active": true,
+			"content":     map[string]interface{}{"title": "scheduled"},
+			"active_from": future,
+		})
+		env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{4}, "feature_id": 1, "is_active": true,
+			"content":      map[string]interface{}{"title": "expired"},
+			"active_until": past,
+		})
+
+		tests := []struct {
+			name   string
+			query  string
+			token  string
+			status int
+			title  string
+		}{
+			{"active banner", "tag_id=1&feature_id=1", env.userToken, http.StatusOK, "active"},
+			{"inactive banner for user", "tag_id=2&feature_id=1", env.userToken, http.StatusNotFound, ""},
+			{"inactive banner for admin", "tag_id=2&feature_id=1", env.adminToken, http.StatusOK, "inactive"},
+			{"before activation window", "tag_id=3&feature_id=1", env.userToken, http.StatusNotFound, ""},
+			{"after activation window", "tag_id=4&feature_id=1", env.userToken, http.StatusNotFound, ""},
+			{"unknown feature", "tag_id=1&feature_id=2", env.userToken, http.StatusNotFound, ""},
+			{"missing tag", "feature_id=1", env.userToken, http.StatusBadRequest, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				status, data := env.do(t, http.MethodGet, "/user_banner?use_last_revision=true&"+tt.query, tt.token, nil)
+				if status != tt.status {
+					t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, status, data)
+				}
+				if tt.title != "" {
+					if title := userBannerContent(t, data)["title"]; title != tt.title {
+						t.Fatalf("ожидался баннер %q, получен %v", tt.title, title)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestLegacyUserBannerByID(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		id := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "legacy"},
+		})
+
+		tests := []struct {
+			name   string
+			path   string
+			token  string
+			status int
+		}{
+			{"admin", "/user_banner/" + strconv.Itoa(id), env.adminToken, http.StatusOK},
+			{"user", "/user_banner/" + strconv.Itoa(id), env.userToken, http.StatusUnauthorized},
+			{"without token", "/user_banner/" + strconv.Itoa(id), "", http.StatusUnauthorized},
+			{"unknown banner", "/user_banner/999999", env.adminToken, http.StatusNotFound},
+			{"invalid id", "/user_banner/abc", env.adminToken, http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				header := http.Header{}
+				if tt.token != "" {
+					header.Set("Authorization", tt.token)
+				}
+				resp, data := env.doWithHeader(t, http.MethodGet, tt.path, header, nil)
+				if resp.StatusCode != tt.status {
+					t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, resp.StatusCode, data)
+				}
+				if tt.status != http.StatusOK {
+					return
+				}
+				if resp.Header.Get("Deprecation") == "" {
+					t.Error("устаревший маршрут должен возвращать заголовок Deprecation")
+				}
+				if title := userBannerContent(t, data)["title"]; title != "legacy" {
+					t.Fatalf("ожидался баннер %q, получен %v", "legacy", title)
+				}
+			})
+		}
+	})
+}
+
+func TestUserBannerCacheFreshness(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		id := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "old"},
+		})
+
+		// Первый запрос кладет баннер в кэш
+		status, data := env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=1", env.userToken, nil)
+		if status != http.StatusOK {
+			t.Fatalf("ожидался статус 200, получен %d: %s", status, data)
+		}
+
+		status, data = env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(id), env.adminToken, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "new"},
+		})
+		if status != http.StatusOK {
+			t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", id, status, data)
+		}
+
+		// Изменение удаляет баннер из кэша, поэтому он актуален и без use_last_revision
+		_, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=1", env.userToken, nil)
+		if title := userBannerContent(t, data)["title"]; title != "new" {
+			t.Fatalf("после изменения ожидался актуальный баннер, получен title=%v", title)
+		}
+
+		// Запись в обход сценария не сбрасывает кэш: без use_last_revision виден кэш, с ним - хранилище
+		stored, err := env.repos.banners.GetBannerByID(context.Background(), id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored.JSONStructure = `{"title":"newest"}`
+		if err := env.repos.banners.UpdateBanner(context.Background(), stored); err != nil {
+			t.Fatal(err)
+		}
+		_, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=1", env.userToken, nil)
+		if title := userBannerContent(t, data)["title"]; title != "new" {
+			t.Fatalf("без use_last_revision ожидался баннер из кэша, получен title=%v", title)
+		}
+		_, data = env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=1&use_last_revision=true", env.userToken, nil)
+		if title := userBannerContent(t, data)["title"]; title != "newest" {
+			t.Fatalf("с use_last_revision ожидался актуальный баннер, получен title=%v", title)
+		}
+
+		status, data = env.do(t, http.MethodDelete, "/banner/"+strconv.Itoa(id), env.adminToken, nil)
+		if status != http.StatusNoContent {
+			t.Fatalf("DELETE /banner/%d: ожидался статус 204, получен %d: %s", id, status, data)
+		}
+		if status, data := env.do(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=1", env.userToken, nil); status != http.StatusNotFound {
+			t.Fatalf("после удаления ожидался статус 404, получен %d: %s", status, data)
+		}
+	})
+}
+
+func TestRedisBannerCacheReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// Две реплики сервиса с общим хранилищем и общим кэшем
+	repos := newMemoryStorage()
+	repos.cache = cache.NewRedisBannerCache(client, time.Minute, "test:")
+	first := newTestEnv(t, repos)
+	second := newTestEnv(t, repos)
+
+	id := first.createBanner(t, map[string]interface{}{
+		"tag_ids": []int{2}, "feature_id": 7, "is_active": true,
+		"content": map[string]interface{}{"title": "old"},
+	})
+	if _, data := first.do(t, http.MethodGet, "/user_banner?tag_id=2&feature_id=7", first.userToken, nil); userBannerContent(t, data)["title"] != "old" {
+		t.Fatalf("ожидался баннер old, получено %s", data)
+	}
+	if ttl := server.TTL("test:user_banner:2:7"); ttl != time.Minute {
+		t.Fatalf("ожидалась запись в Redis с TTL 1m, получен TTL %s", ttl)
+	}
+
+	// Изменение на одной реплике сразу видно на другой
+	status, data := second.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(id), second.adminToken, map[string]interface{}{
+		"tag_ids": []int{2}, "feature_id": 7, "is_active": true,
+		"content": map[string]interface{}{"title": "new"},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", id, status, data)
+	}
+	if server.Exists("test:user_banner:2:7") {
+		t.Fatal("после изменения запись должна быть удалена из Redis")
+	}
+	if _, data := first.do(t, http.MethodGet, "/user_banner?tag_id=2&feature_id=7", first.userToken, nil); userBannerContent(t, data)["title"] != "new" {
+		t.Fatalf("ожидался баннер new, получено %s", data)
+	}
+
+	// Недоступный кэш не мешает чтению из хранилища
+	server.Close()
+	if status, data := first.do(t, http.MethodGet, "/user_banner?tag_id=2&feature_id=7", first.userToken, nil); status != http.StatusOK {
+		t.Fatalf("без Redis ожидался статус 200, получен %d: %s", status, data)
+	}
+}
+
+// unavailableBanners имитирует недоступную базу данных для чтения баннеров пользователей и загрузки снимка
+type unavailableBanners struct {
+	usecase.BannerRepository
+	down atomic.Bool
+}
+
+func (repo *unavailableBanners) GetUserBanner(ctx context.Context, tagID, featureID int, now time.Time) (*entity.Banner, error) {
+	if repo.down.Load() {
+		return nil, errors.New("база данных недоступна")
+	}
+	return repo.BannerRepository.GetUserBanner(ctx, tagID, featureID, now)
+}
+
+func (repo *unavailableBanners) GetSnapshotBanners(ctx context.Context) ([]*entity.Banner, error) {
+	if repo.down.Load() {
+		return nil, errors.New("база данных недоступна")
+	}
+	return repo.BannerRepository.GetSnapshotBanners(ctx)
+}
+
+func TestUserBannerSnapshot(t *testing.T) {
+	banners := &unavailableBanners{BannerRepository: memory.NewBannerRepository()}
+	repos := newMemoryStorage()
+	repos.banners = banners
+	repos.snapshot = usecase.NewBannerSnapshot(banners, time.Hour)
+	env := newTestEnv(t, repos)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := repos.snapshot.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go repos.snapshot.Run(ctx)
+
+	// waitTitle ждет, пока фоновое обновление снимка не покажет баннер с заданным заголовком
+	waitTitle := func(title string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			status, data := env.do(t, http.MethodGet, "/user_banner?tag_id=3&feature_id=5", env.userToken, nil)
+			if status == http.StatusOK && userBannerContent(t, data)["title"] == title {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("ожидался баннер %q, получен статус %d: %s", title, status, data)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Пустой снимок отвечает 404, не обращаясь к репозиторию
+	banners.down.Store(true)
+	if status, data := env.do(t, http.MethodGet, "/user_banner?tag_id=3&feature_id=5", env.userToken, nil); status != http.StatusNotFound {
+		t.Fatalf("ожидался статус 404, получен %d: %s", status, data)
+	}
+	banners.down.Store(false)
+
+	// Изменения баннеров обновляют снимок, не дожидаясь интервала
+	id := env.createBanner(t, map[string]interface{}{
+		"tag_ids": []int{3}, "feature_id": 5, "is_active": true,
+		"content": map[string]interface{}{"title": "old"},
+	})
+	waitTitle("old")
+	status, data := env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(id), env.adminToken, map[string]interface{}{
+		"tag_ids": []int{3}, "feature_id": 5, "is_active": true,
+		"content": map[string]interface{}{"title": "new"},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", id, status, data)
+	}
+	waitTitle("new")
+
+	// При недоступной базе данных снимок продолжает отвечать, а неудачное обновление его не портит
+	banners.down.Store(true)
+	if err := repos.snapshot.Refresh(ctx); err == nil {
+		t.Fatal("ожидалась ошибка обновления снимка при недоступной базе данных")
+	}
+	waitTitle("new")
+
+	// use_last_revision по-прежнему читает базу данных
+	if status, data := env.do(t, http.MethodGet, "/user_banner?tag_id=3&feature_id=5&use_last_revision=true", env.userToken, nil); status != http.StatusInternalServerError {
+		t.Fatalf("с use_last_revision ожидался статус 500, получен %d: %s", status, data)
+	}
+}
+
+func TestBannerEventFeed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// Подписчик, не успевающий разбирать события, после разбора буфера получает resync
+	bus := usecase.NewBannerEventBus()
+	release := make(chan struct{})
+	received := make(chan entity.BannerEvent, 1024)
+	slowCtx, stopSlow := context.WithCancel(ctx)
+	bus.Subscribe(slowCtx, "slow", func(ctx context.Context, event entity.BannerEvent) {
+		<-release
+		received <- event
+	})
+	for i := 1; i <= 1000; i++ {
+		bus.Publish(entity.BannerEvent{BannerID: i, Change: entity.BannerEventUpdate})
+	}
+	close(release)
+	for {
+		select {
+		case event := <-received:
+			if event.Change != entity.BannerEventResync {
+				continue
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("после переполнения буфера ожидалось событие resync")
+		}
+		break
+	}
+
+	// После отмены контекста Wait дожидается остановки подписчика
+	stopSlow()
+	stopped := make(chan struct{})
+	go func() {
+		bus.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait не дождался остановки подписчика")
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := postgresDSN(t)
+		banners := db.NewBannerRepository(openDisposableSchema(t, dsn))
+		banners.Origin = "replica-a"
+		bus := usecase.NewBannerEventBus()
+		events := make(chan entity.BannerEvent, 16)
+		bus.Subscribe(ctx, "test", func(ctx context.Context, event entity.BannerEvent) {
+			events <- event
+		})
+		go db.NewBannerListener(dsn, bus).Run(ctx)
+
+		// waitEvent ждет события по баннеру id; другие события пропускаются
+		waitEvent := func(id int, timeout time.Duration) (entity.BannerEvent, bool) {
+			deadline := time.After(timeout)
+			for {
+				select {
+				case event := <-events:
+					if event.BannerID == id {
+						return event, true
+					}
+				case <-deadline:
+					return entity.BannerEvent{}, false
+				}
+			}
+		}
+
+		// Слушатель подключается асинхронно, поэтому баннеры создаются, пока событие не дойдет
+		var banner *entity.Banner
+		for attempt := 0; ; attempt++ {
+			banner = &entity.Banner{JSONStructure: `{"title":"feed"}`, FeatureID: 1, TagIDs: []int{1}, IsActive: true}
+			if err := banners.CreateBanner(ctx, banner); err != nil {
+				t.Fatal(err)
+			}
+			if event, ok := waitEvent(banner.ID, 500*time.Millisecond); ok {
+				// Событие несет реплику-источник и пары баннера, чтобы подписчики удаляли из кэша только их
+				if event.Change != entity.BannerEventCreate || event.Origin != "replica-a" || event.FeatureID != 1 || len(event.TagIDs) != 1 || event.TagIDs[0] != 1 {
+					t.Fatalf("ожидалось событие create от replica-a с парой (1, 1), получено %+v", event)
+				}
+				break
+			}
+			if attempt == 10 {
+				t.Fatal("событие создания баннера не получено")
+			}
+		}
+
+		if err := banners.DeleteBannerByID(ctx, banner.ID); err != nil {
+			t.Fatal(err)
+		}
+		if event, ok := waitEvent(banner.ID, 5*time.Second); !ok || event.Change != entity.BannerEventDelete {
+			t.Fatalf("ожидалось событие delete, получено %+v", event)
+		}
+	})
+}
+
+func TestUserBannerConditionalGet(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		id := env.createBanner(t, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 6, "is_active": true,
+			"content": map[string]interface{}{"title": "old"},
+		})
+
+		// get запрашивает баннер пользователя с заданным If-None-Match
+		get := func(query, ifNoneMatch string) (*http.Response, []byte) {
+			t.Helper()
+			header := http.Header{"Authorization": {env.userToken}}
+			if ifNoneMatch != "" {
+				header.Set("If-None-Match", ifNoneMatch)
+			}
+			return env.doWithHeader(t, http.MethodGet, "/user_banner?tag_id=1&feature_id=6"+query, header, nil)
+		}
+
+		resp, data := get("", "")
+		etag := resp.Header.Get("ETag")
+		if resp.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("ожидался статус 200 с ETag, получен %d с %q: %s", resp.StatusCode, etag, data)
+		}
+		if cacheControl := resp.Header.Get("Cache-Control"); cacheControl != "private, max-age=300" {
+			t.Fatalf("ожидался Cache-Control по TTL кэша, получен %q", cacheControl)
+		}
+
+		for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+			resp, data = get("", ifNoneMatch)
+			if resp.StatusCode != http.StatusNotModified || len(data) != 0 || resp.Header.Get("ETag") != etag {
+				t.Fatalf("If-None-Match %s: ожидался статус 304 без тела, получен %d: %s", ifNoneMatch, resp.StatusCode, data)
+			}
+		}
+
+		// Измененный баннер получает новый ETag, а с use_last_revision ответ нужно перепроверять
+		status, data := env.do(t, http.MethodPatch, "/banner/"+strconv.Itoa(id), env.adminToken, map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 6, "is_active": true,
+			"content": map[string]interface{}{"title": "new"},
+		})
+		if status != http.StatusOK {
+			t.Fatalf("PATCH /banner/%d: ожидался статус 200, получен %d: %s", id, status, data)
+		}
+		resp, data = get("&use_last_revision=true", etag)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+			t.Fatalf("после изменения ожидался статус 200 с новым ETag, получен %d с %q: %s", resp.StatusCode, resp.Header.Get("ETag"), data)
+		}
+		if title := userBannerContent(t, data)["title"]; title != "new" {
+			t.Fatalf("ожидался актуальный баннер, получен title=%v", title)
+		}
+		if cacheControl := resp.Header.Get("Cache-Control"); cacheControl != "private, no-cache" {
+			t.Fatalf("с use_last_revision ожидался Cache-Control private, no-cache, получен %q", cacheControl)
+		}
+	})
+}
+
+func TestAuthFailures(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, env *testEnv) {
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			status int
+			code   string
+		}{
+			{"user banner without token", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", http.StatusUnauthorized, api.CodeUnauthorized},
+			{"user banner with invalid token", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "garbage", http.StatusUnauthorized, api.CodeUnauthorized},
+			{"banner list without token", http.MethodGet, "/banner?limit=10", "", http.StatusUnauthorized, api.CodeUnauthorized},
+			{"banner list with user token", http.MethodGet, "/banner?limit=10", env.userToken, http.StatusForbidden, api.CodeForbidden},
+			{"create banner with user token", http.MethodPost, "/banner", env.userToken, http.StatusForbidden, api.CodeForbidden},
+			{"delete banner with user token", http.MethodDelete, "/banner/1", env.userToken, http.StatusForbidden, api.CodeForbidden},
+			{"schedule with user token", http.MethodGet, "/banner/schedule", env.userToken, http.StatusForbidden, api.CodeForbidden},
+		}
+
+		body := map[string]interface{}{
+			"tag_ids": []int{1}, "feature_id": 1, "is_active": true,
+			"content": map[string]interface{}{"title": "t"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var reqBody interface{}
+				if tt.method == http.MethodPost {
+					reqBody = body
+				}
+				status, data := env.do(t, tt.method, tt.path, tt.token, reqBody)
+				if status != tt.status {
+					t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, status, data)
+				}
+
+				var apiErr struct {
+					Code string `json:"code"`
+				}
+				if err := json.Unmarshal(data, &apiErr); err != nil || apiErr.Code != tt.code {
+					t.Fatalf("ожидался код %q, получен ответ %s", tt.code, data)
+				}
+			})
+		}
+	})
+}
+
+func TestErrorLocalization(t *testing.T) {
+	env := newTestEnv(t, newMemoryStorage())
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		acceptLanguage string
+		status         int
+		code           string
+		message        string
+	}{
+		{"default language", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", "", http.StatusUnauthorized, api.CodeUnauthorized, "Пользователь не авторизован"},
+		{"english", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", "en-US,en;q=0.9", http.StatusUnauthorized, api.CodeUnauthorized, "User is not authorized"},
+		{"russian region", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", "ru-RU", http.StatusUnauthorized, api.CodeUnauthorized, "Пользователь не авторизован"},
+		{"unsupported language", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", "de", http.StatusUnauthorized, api.CodeUnauthorized, "Пользователь не авторизован"},
+		{"malformed header", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", ";;q=abc", http.StatusUnauthorized, api.CodeUnauthorized, "Пользователь не авторизован"},
+		{"fallback to supported language", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", "de-DE,en;q=0.5", http.StatusUnauthorized, api.CodeUnauthorized, "User is not authorized"},
+		{"quality order", http.MethodGet, "/user_banner?tag_id=1&feature_id=1", "", "en;q=0.3,ru;q=0.8", http.StatusUnauthorized, api.CodeUnauthorized, "Пользователь не авторизован"},
+		{"forbidden in english", http.MethodGet, "/banner?limit=10", env.userToken, "en", http.StatusForbidden, api.CodeForbidden, "User has no access"},
+		{"invalid params in english", http.MethodGet, "/user_banner?tag_id=abc&feature_id=1", env.adminToken, "en", http.StatusBadRequest, api.CodeInvalidParams, "Invalid request data"},
+		{"invalid params in russian", http.MethodGet, "/user_banner?tag_id=abc&feature_id=1", env.adminToken, "ru", http.StatusBadRequest, api.CodeInvalidParams, "Некорректные данные"},
+		{"banner not found in english", http.MethodDelete, "/banner/999999", env.adminToken, "en", http.StatusNotFound, api.CodeBannerNotFound, "Banner not found"},
+		{"banner not found in russian", http.MethodDelete, "/banner/999999", env.adminToken, "ru", http.StatusNotFound, api.CodeBannerNotFound, "Баннер не найден"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Accept-Language", tt.acceptLanguage)
+			if tt.token != "" {
+				header.Set("Authorization", tt.token)
+			}
+
+			resp, data := env.doWithHeader(t, tt.method, tt.path, header, nil)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, resp.StatusCode, data)
+			}
+
+			var apiErr struct {
+				Code  string `json:"code"`
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(data, &apiErr); err != nil {
+				t.Fatal(err)
+			}
+			if apiErr.Code != tt.code || apiErr.Error != tt.message {
+				t.Errorf("Accept-Language %q: ожидались %q/%q, получены %q/%q",
+					tt.acceptLanguage, tt.code, tt.message, apiErr.Code, apiErr.Error)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	env := newTestEnv(t, newMemoryStorage())
+
+	tests := []struct {
+		name      string
+		requestID string
+		kept      bool
+	}{
+		{"valid", "trace-01.A_b", true},
+		{"max length", strings.Repeat("a", 64), true},
+		{"too long", strings.Repeat("a", 65), false},
+		{"space", "bad id", false},
+		{"markup", "<script>", false},
+		{"non ascii", "запрос", false},
+		{"missing", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.requestID != "" {
+				header.Set(api.RequestIDHeader, tt.requestID)
+			}
+
+			resp, data := env.doWithHeader(t, http.MethodGet, "/ping", header, nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("ожидался статус 200, получен %d: %s", resp.StatusCode, data)
+			}
+			got := resp.Header.Get(api.RequestIDHeader)
+			if tt.kept && got != tt.requestID {
+				t.Fatalf("ожидался идентификатор %q, получен %q", tt.requestID, got)
+			}
+			if !tt.kept && (got == tt.requestID || len(got) != 32) {
+				t.Fatalf("ожидался новый идентификатор вместо %q, получен %q", tt.requestID, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+// AuditHandlers представляет обработчики запросов к журналу аудита
+type AuditHandlers struct {
+	AuditUseCase *usecase.AuditUseCase
+}
+
+// NewAuditHandlers создает новый экземпляр AuditHandlers
+func NewAuditHandlers(auditUseCase *usecase.AuditUseCase) *AuditHandlers {
+	return &AuditHandlers{AuditUseCase: auditUseCase}
+}
+
+// GetAuditLogHandler обработчик для получения журнала аудита с фильтрами по сущности, автору и периоду
+func (h *AuditHandlers) GetAuditLogHandler(c *gin.Context) {
+	filter := entity.AuditFilter{Entity: c.Query("entity")}
+
+	var err error
+	if actorID := c.Query("actor_id"); actorID != "" {
+		if filter.ActorID, err = strconv.Atoi(actorID); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+	// Границы периода в формате RFC 3339: from включительно, to не включительно
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+
+	token := c.GetHeader("Authorization")
+	records, err := h.AuditUseCase.GetAuditLog(c.Request.Context(), filter, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
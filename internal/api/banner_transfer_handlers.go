@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
+	"Avito_task/internal/usecase"
+)
+
+// maxImportBodySize ограничивает размер файла импорта баннеров
+const maxImportBodySize = 32 << 20
+
+// bannerFormatContentTypes сопоставляет форматы выгрузки баннеров с типами содержимого
+var bannerFormatContentTypes = map[string]string{
+	entity.BannerFormatNDJSON: "application/x-ndjson",
+	entity.BannerFormatCSV:    "text/csv; charset=utf-8",
+}
+
+// ExportBannersHandler обработчик для выгрузки всех баннеров в формате NDJSON или CSV
+func (h *BannerHandlers) ExportBannersHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", entity.BannerFormatNDJSON)
+	contentType, ok := bannerFormatContentTypes[format]
+	if !ok {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	writer := &streamWriter{c: c, contentType: contentType, filename: "banners." + format}
+	err := h.BannerUseCase.ExportBanners(c.Request.Context(), writer, format, token)
+	if err != nil {
+		if writer.started {
+			// Заголовки уже отправлены, поэтому клиент узнает об ошибке только по оборванному ответу
+			logging.FromContext(c.Request.Context()).Error("ошибка при выгрузке баннеров", "error", err)
+			return
+		}
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	// Пустая выгрузка NDJSON не записывает ни одного байта
+	writer.start()
+}
+
+// ImportBannersHandler обработчик для загрузки баннеров из NDJSON или CSV; при dry_run изменения не сохраняются
+func (h *BannerHandlers) ImportBannersHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", entity.BannerFormatNDJSON)
+	if _, ok := bannerFormatContentTypes[format]; !ok {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+
+	token := c.GetHeader("Authorization")
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodySize)
+	report, err := h.BannerUseCase.ImportBanners(c.Request.Context(), body, format, dryRun, token)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			respondError(c, http.StatusRequestEntityTooLarge, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// streamWriter выставляет заголовки ответа только при первой записи,
+// чтобы до нее на ошибку можно было ответить обычным JSON
+type streamWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *streamWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.c.Header("Content-Type", w.contentType)
+	w.c.Header("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+	w.c.Status(http.StatusOK)
+	w.c.Writer.WriteHeaderNow()
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.start()
+	n, err := w.c.Writer.Write(p)
+	w.c.Writer.Flush()
+	return n, err
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+
+	"Avito_task/internal/logging"
+)
+
+// Коды ошибок, которые возвращаются клиенту в поле "code" и не зависят от языка
+const (
+	CodeInvalidParams        = "invalid_params"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeBannerNotFound       = "banner_not_found"
+	CodeUserBannerNotFound   = "user_banner_not_found"
+	CodeInternalServerError  = "internal_server_error"
+	CodeRequestTimeout       = "request_timeout"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeAccountLocked        = "account_locked"
+	CodeGrantNotFound        = "grant_not_found"
+	CodeAPIKeyNotFound       = "api_key_not_found"
+	CodeVersionConflict      = "version_conflict"
+	CodePreconditionRequired = "precondition_required"
+)
+
+// supportedLanguages перечисляет языки каталога; первый используется по умолчанию
+var supportedLanguages = []language.Tag{
+	language.Russian,
+	language.English,
+}
+
+var languageMatcher = language.NewMatcher(supportedLanguages)
+
+// messages содержит каталог сообщений об ошибках по языку и коду ошибки
+var messages = map[string]map[string]string{
+	"ru": {
+		CodeInvalidParams:        "Некорректные данные",
+		CodeUnauthorized:         "Пользователь не авторизован",
+		CodeForbidden:            "Пользователь не имеет доступа",
+		CodeBannerNotFound:       "Баннер не найден",
+		CodeUserBannerNotFound:   "Баннер для пользователя не найден",
+		CodeInternalServerError:  "Внутренняя ошибка сервера",
+		CodeRequestTimeout:       "Превышено время обработки запроса",
+		CodeTooManyRequests:      "Слишком много запросов, повторите позже",
+		CodeAccountLocked:        "Вход временно заблокирован после неудачных попыток",
+		CodeGrantNotFound:        "Разрешение не найдено",
+		CodeAPIKeyNotFound:       "API ключ не найден",
+		CodeVersionConflict:      "Баннер изменен другим запросом, получите его актуальную версию",
+		CodePreconditionRequired: "Для изменения баннера нужен заголовок If-Match с ETag его версии",
+	},
+	"en": {
+		CodeInvalidParams:        "Invalid request data",
+		CodeUnauthorized:         "User is not authorized",
+		CodeForbidden:            "User has no access",
+		CodeBannerNotFound:       "Banner not found",
+		CodeUserBannerNotFound:   "Banner for user not found",
+		CodeInternalServerError:  "Internal server error",
+		CodeRequestTimeout:       "Request timed out",
+		CodeTooManyRequests:      "Too many requests, retry later",
+		CodeAccountLocked:        "Login is temporarily locked after failed attempts",
+		CodeGrantNotFound:        "Grant not found",
+		CodeAPIKeyNotFound:       "API key not found",
+		CodeVersionConflict:      "Banner was modified by another request, fetch its current version",
+		CodePreconditionRequired: "Updating a banner requires an If-Match header with its version ETag",
+	},
+}
+
+// negotiateLanguage выбирает язык сообщений по заголовку Accept-Language
+func negotiateLanguage(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return "ru"
+	}
+
+	_, index, confidence := languageMatcher.Match(tags...)
+	if confidence == language.No {
+		return "ru"
+	}
+	base, _ := supportedLanguages[index].Base()
+	return base.String()
+}
+
+// localizeMessage возвращает текст ошибки для кода на выбранном языке
+func localizeMessage(lang, code string) string {
+	if message, ok := messages[lang][code]; ok {
+		return message
+	}
+	if message, ok := messages["ru"][code]; ok {
+		return message
+	}
+	return code
+}
+
+// respondError отправляет ответ с кодом ошибки и локализованным сообщением
+func respondError(c *gin.Context, status int, code string) {
+	lang := negotiateLanguage(c.GetHeader("Accept-Language"))
+	c.JSON(status, gin.H{
+		"code":  code,
+		"error": localizeMessage(lang, code),
+	})
+}
+
+// StatusClientClosedRequest используется, когда клиент закрыл соединение до получения ответа
+const StatusClientClosedRequest = 499
+
+// respondInternalError логирует причину ошибки и отправляет ответ 500;
+// истекший дедлайн запроса превращается в 504, а отключение клиента - в 499
+func respondInternalError(c *gin.Context, err error) {
+	ctx := c.Request.Context()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		logging.FromContext(ctx).Warn("истекло время обработки запроса", "route", c.FullPath(), "error", err)
+		respondError(c, http.StatusGatewayTimeout, CodeRequestTimeout)
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		logging.FromContext(ctx).Info("клиент закрыл соединение", "route", c.FullPath())
+		c.AbortWithStatus(StatusClientClosedRequest)
+	default:
+		logging.FromContext(ctx).Error("ошибка обработки запроса",
+			"route", c.FullPath(),
+			"error", err,
+		)
+		respondError(c, http.StatusInternalServerError, CodeInternalServerError)
+	}
+}
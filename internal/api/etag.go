@@ -0,0 +1,56 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// bannerETag возвращает сильный ETag версии баннера
+func bannerETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch возвращает версии баннера из списка ETag заголовка If-Match. Пустой заголовок и "*" дают nil,
+// то есть обновление без проверки; обязательность заголовка проверяет ifMatchMiddleware.
+// Слабые и некорректные ETag не совпадают ни с одной версией; если в списке нет других, возвращается false
+func parseIfMatch(header string) ([]int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	var versions []int
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if len(candidate) < 2 || candidate[0] != '"' || candidate[len(candidate)-1] != '"' {
+			continue
+		}
+		version, err := strconv.Atoi(candidate[1 : len(candidate)-1])
+		if err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions, len(versions) > 0
+}
+
+// userBannerETag возвращает ETag ответа /user_banner по версии баннера и телу ответа, в которое входит
+// выбранный вариант содержимого; поэтому разные варианты одного баннера получают разные ETag
+func userBannerETag(version int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.Itoa(version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// matchesIfNoneMatch сообщает, есть ли etag в списке заголовка If-None-Match; по RFC 9110 сравнение слабое
+func matchesIfNoneMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
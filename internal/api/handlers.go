@@ -1,8 +1,12 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,35 +16,112 @@ import (
 
 // BannerHandlers представляет обработчики запросов для баннеров
 type BannerHandlers struct {
-	BannerUseCase *usecase.BannerUseCase
+	BannerUseCase   *usecase.BannerUseCase
+	BannerScheduler *usecase.BannerScheduler
+	StatsUseCase    *usecase.StatsUseCase
 }
 
 // NewBannerHandlers создает новый экземпляр BannerHandlers
-func NewBannerHandlers(bannerUseCase *usecase.BannerUseCase) *BannerHandlers {
+func NewBannerHandlers(bannerUseCase *usecase.BannerUseCase, bannerScheduler *usecase.BannerScheduler, statsUseCase *usecase.StatsUseCase) *BannerHandlers {
 	return &BannerHandlers{
-		BannerUseCase: bannerUseCase,
+		BannerUseCase:   bannerUseCase,
+		BannerScheduler: bannerScheduler,
+		StatsUseCase:    statsUseCase,
 	}
 }
 
-// GetUserBannerHandler обработчик для получения баннера пользователя по ID
+// GetUserBannerHandler обработчик для получения баннера пользователя по тегу и фиче
 func (h *BannerHandlers) GetUserBannerHandler(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
+	tagID, err := strconv.Atoi(c.Query("tag_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+	featureID, err := strconv.Atoi(c.Query("feature_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+	useLastRevision, err := strconv.ParseBool(c.DefaultQuery("use_last_revision", "false"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные"})
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
 		return
 	}
 
 	token := c.GetHeader("Authorization")
-	banner, err := h.BannerUseCase.GetUserBanner(id, token)
+	banner, err := h.BannerUseCase.GetUserBanner(c.Request.Context(), tagID, featureID, useLastRevision, token)
 	if err != nil {
-		switch err {
-		case usecase.ErrUnauthorized:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
-		case usecase.ErrBannerNotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": "Баннер для пользователя не найден"})
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrBannerNotFound):
+			respondError(c, http.StatusNotFound, CodeUserBannerNotFound)
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Внутренняя ошибка сервера"})
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	// Учитываем показ баннера в статистике
+	h.StatsUseCase.RecordImpression(banner, tagID)
+
+	// Сообщаем клиенту, какой вариант содержимого был показан
+	if banner.VariantID != 0 {
+		c.Header("X-Banner-Variant", strconv.Itoa(banner.VariantID))
+	}
+
+	body, err := json.Marshal(banner)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	// Клиент может хранить ответ столько же, сколько его хранит кэш сервера; use_last_revision просит актуальную версию,
+	// поэтому такой ответ нужно перепроверять. Ответ зависит от токена, поэтому общим кэшам он недоступен
+	cacheControl := "private, no-cache"
+	if ttl := h.BannerUseCase.CacheTTL(); ttl > 0 && !useLastRevision {
+		cacheControl = "private, max-age=" + strconv.Itoa(int(ttl.Seconds()))
+	}
+	etag := userBannerETag(banner.Version, body)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", etag)
+
+	// Показ учтен выше: клиент показывает баннер и тогда, когда берет его из своего кэша
+	if matchesIfNoneMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// GetUserBannerByIDHandler обработчик устаревшего маршрута получения баннера по ID; вместо него следует
+// использовать GET /user_banner?tag_id=...&feature_id=...
+func (h *BannerHandlers) GetUserBannerByIDHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	// Сообщаем клиенту, что маршрут устарел, и указываем замену
+	c.Header("Deprecation", "true")
+	c.Header("Link", `</user_banner>; rel="successor-version"`)
+
+	token := c.GetHeader("Authorization")
+	banner, err := h.BannerUseCase.GetUserBannerByID(c.Request.Context(), id, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrBannerNotFound):
+			respondError(c, http.StatusNotFound, CodeUserBannerNotFound)
+		default:
+			respondInternalError(c, err)
 		}
 		return
 	}
@@ -50,29 +131,59 @@ func (h *BannerHandlers) GetUserBannerHandler(c *gin.Context) {
 
 // GetAllBannersHandler обработчик для получения всех баннеров с учетом фильтров
 func (h *BannerHandlers) GetAllBannersHandler(c *gin.Context) {
-	tagIDStr := c.Query("tag_id")
-	featureIDStr := c.Query("feature_id")
-	limitStr := c.DefaultQuery("limit", "0")
-	offsetStr := c.DefaultQuery("offset", "0")
+	tagID, _ := strconv.Atoi(c.Query("tag_id"))
+	featureID, _ := strconv.Atoi(c.Query("feature_id"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	// Направление сортировки: asc по умолчанию или desc
+	order := c.DefaultQuery("order", "asc")
+	if order != "asc" && order != "desc" {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	// Фильтры по полям содержимого вида "content.url contains avito.ru"
+	var contentFilters []entity.ContentFilter
+	for _, raw := range c.QueryArray("filter") {
+		filter, err := entity.ParseContentFilter(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+		contentFilters = append(contentFilters, filter)
+	}
 
-	tagID, _ := strconv.Atoi(tagIDStr)
-	featureID, _ := strconv.Atoi(featureIDStr)
-	limit, _ := strconv.Atoi(limitStr)
-	offset, _ := strconv.Atoi(offsetStr)
+	query := entity.BannerListQuery{
+		BannerFilter: entity.BannerFilter{
+			TagID:     tagID,
+			FeatureID: featureID,
+			Search:    strings.TrimSpace(c.Query("q")),
+			Content:   contentFilters,
+		},
+		Sort:   c.Query("sort"),
+		Desc:   order == "desc",
+		Limit:  limit,
+		Offset: offset,
+	}
 
 	token := c.GetHeader("Authorization")
-	banners, err := h.BannerUseCase.GetAllBanners(tagID, featureID, limit, offset, token)
+	page, err := h.BannerUseCase.GetAllBanners(c.Request.Context(), query, c.Query("cursor"), token)
 	if err != nil {
-		switch err {
-		case usecase.ErrUnauthorized:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Внутренняя ошибка сервера"})
+			respondInternalError(c, err)
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, banners)
+	c.JSON(http.StatusOK, page)
 }
 
 // CreateBanner обработчик для создания нового баннера
@@ -80,52 +191,232 @@ func (h *BannerHandlers) CreateBanner(c *gin.Context) {
 	// Получаем параметры из контекста Gin
 	var req entity.CreateBannerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные"})
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
 		return
 	}
 	token := c.GetHeader("Authorization")
 
 	// Вызываем метод usecase для создания нового баннера
-	newBanner, err := h.BannerUseCase.CreateBanner(req.TagIDs, req.FeatureID, req.Content, req.IsActive, token)
+	newBanner, err := h.BannerUseCase.CreateBanner(c.Request.Context(), req.TagIDs, req.FeatureID, req.Content, req.IsActive, req.ActiveFrom, req.ActiveUntil, req.Variants, token)
 	if err != nil {
 		// Обработка ошибок и отправка соответствующих HTTP-ответов
-		switch err {
-		case usecase.ErrInvalidParams:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные"})
-		case usecase.ErrUnauthorized:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Внутренняя ошибка сервера"})
+			respondInternalError(c, err)
 		}
 		return
 	}
 
 	// Отправляем созданный баннер в качестве ответа
+	c.Header("ETag", bannerETag(newBanner.Version))
 	c.JSON(http.StatusCreated, newBanner)
 }
 
+// UpdateBannerHandler обработчик для обновления баннера по его ID
+func (h *BannerHandlers) UpdateBannerHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	var req entity.UpdateBannerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+	token := c.GetHeader("Authorization")
+
+	// If-Match со списком ETag версий защищает от перезаписи чужих изменений; "*" обновляет любую версию,
+	// а без заголовка запрос отклоняет ifMatchMiddleware, если RouterConfig.RequireIfMatch
+	versions, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		respondError(c, http.StatusPreconditionFailed, CodeVersionConflict)
+		return
+	}
+
+	updatedBanner, err := h.BannerUseCase.UpdateBanner(c.Request.Context(), id, req.TagIDs, req.FeatureID, req.Content, req.IsActive, req.ActiveFrom, req.ActiveUntil, req.Variants, versions, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrBannerNotFound):
+			respondError(c, http.StatusNotFound, CodeBannerNotFound)
+		case errors.Is(err, entity.ErrVersionConflict):
+			respondError(c, http.StatusPreconditionFailed, CodeVersionConflict)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", bannerETag(updatedBanner.Version))
+	c.Status(http.StatusOK)
+}
+
+// GetBannerScheduleHandler обработчик для получения предстоящих включений и выключений баннеров
+func (h *BannerHandlers) GetBannerScheduleHandler(c *gin.Context) {
+	token := c.GetHeader("Authorization")
+	events, err := h.BannerScheduler.GetSchedule(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ClickBannerHandler обработчик для учета клика пользователя по баннеру
+func (h *BannerHandlers) ClickBannerHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+	tagID, err := strconv.Atoi(c.Query("tag_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.StatsUseCase.RecordClick(c.Request.Context(), id, tagID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrBannerNotFound):
+			respondError(c, http.StatusNotFound, CodeBannerNotFound)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetBannerStatsHandler обработчик для получения дневной статистики показов и кликов баннера
+func (h *BannerHandlers) GetBannerStatsHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	// По умолчанию возвращаем статистику за последние 30 дней
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.Query("from"); fromStr != "" {
+		if from, err = time.Parse(time.DateOnly, fromStr); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		if to, err = time.Parse(time.DateOnly, toStr); err != nil {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+
+	// Группировка строк: по тегам (по умолчанию), по фичам или итог баннера за день
+	groupBy := c.DefaultQuery("group_by", entity.StatsGroupByTag)
+
+	token := c.GetHeader("Authorization")
+	stats, err := h.StatsUseCase.GetBannerStats(c.Request.Context(), id, from, to, groupBy, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
 // DeleteBannerHandler обработчик для удаления баннера по его ID
 func (h *BannerHandlers) DeleteBannerHandler(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные"})
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
 		return
 	}
 
 	token := c.GetHeader("Authorization")
-	err = h.BannerUseCase.DeleteBanner(id, token)
+	err = h.BannerUseCase.DeleteBanner(c.Request.Context(), id, token)
 	if err != nil {
-		switch err {
-		case usecase.ErrUnauthorized:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
-		case usecase.ErrBannerNotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": "Баннер не найден"})
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrBannerNotFound):
+			respondError(c, http.StatusNotFound, CodeBannerNotFound)
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Внутренняя ошибка сервера"})
+			respondInternalError(c, err)
 		}
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// RestoreBannerHandler обработчик для восстановления удаленного баннера
+func (h *BannerHandlers) RestoreBannerHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	banner, err := h.BannerUseCase.RestoreBanner(c.Request.Context(), id, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrBannerNotFound):
+			respondError(c, http.StatusNotFound, CodeBannerNotFound)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, banner)
+}
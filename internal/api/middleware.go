@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/logging"
+	"Avito_task/internal/metrics"
+)
+
+// RequestIDHeader является заголовком с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора запроса, принимаемого от клиента
+const maxRequestIDLength = 64
+
+// requestIDMiddleware берет идентификатор запроса из заголовка или создает новый и кладет его в context.Context.
+// Идентификатор клиента попадает в логи и ответ, поэтому принимается, только если проходит validRequestID
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// timeoutMiddleware ограничивает время обработки запроса дедлайном в context.Context;
+// дедлайн прерывает запросы к базе данных, начатые обработчиком
+func timeoutMiddleware(defaultTimeout time.Duration, routeTimeouts map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routeTimeouts[c.FullPath()]
+		if !ok {
+			timeout = defaultTimeout
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ifMatchMiddleware отвечает 428, если запрос изменения пришел без заголовка If-Match и он обязателен
+func ifMatchMiddleware(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && strings.TrimSpace(c.GetHeader("If-Match")) == "" {
+			respondError(c, http.StatusPreconditionRequired, CodePreconditionRequired)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// loggingMiddleware пишет структурированную запись о каждом обработанном запросе
+func loggingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		logging.FromContext(c.Request.Context()).Info("запрос обработан",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// recoveryMiddleware логирует панику обработчика и отвечает 500
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logging.FromContext(c.Request.Context()).Error("паника при обработке запроса",
+			"route", c.FullPath(),
+			"panic", recovered,
+			"stack", string(debug.Stack()),
+		)
+		respondError(c, http.StatusInternalServerError, CodeInternalServerError)
+		c.Abort()
+	})
+}
+
+// newRequestID создает случайный идентификатор запроса
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// validRequestID проверяет, что идентификатор непустой, не длиннее maxRequestIDLength и состоит из [A-Za-z0-9._-]
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// metricsMiddleware считает запросы и время их обработки по маршруту Gin и статусу ответа
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequestsTotal.WithLabelValues(route, c.Request.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
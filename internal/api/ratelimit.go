@@ -0,0 +1,152 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/auth"
+)
+
+// Группы маршрутов, у каждой из которых свое ограничение частоты запросов
+const (
+	RateLimitGroupUserBanner = "user_banner"
+	RateLimitGroupAdmin      = "admin"
+	RateLimitGroupLogin      = "login"
+	// RateLimitGroupAPIKey ограничивает по IP проверку API ключей, которая выполняется до остальных ограничений
+	RateLimitGroupAPIKey = "api_key"
+)
+
+// RateLimit задает корзину токенов: скорость пополнения и емкость; нулевая скорость отключает ограничение
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// rateLimiterSweepInterval задает, как часто из памяти удаляются полные корзины неактивных клиентов
+const rateLimiterSweepInterval = time.Minute
+
+// tokenBucket хранит число токенов клиента на момент updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter хранит корзины токенов по ключу клиента
+type rateLimiter struct {
+	limit     RateLimit
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// newRateLimiter создает новый экземпляр rateLimiter; емкость корзины не меньше одного запроса
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &rateLimiter{
+		limit:     limit,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow забирает токен из корзины key; если токенов нет, возвращает время до появления следующего
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = bucket
+	} else {
+		l.refill(bucket, now)
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.limit.RequestsPerSecond * float64(time.Second))
+	return false, wait
+}
+
+// refill пополняет корзину за время, прошедшее с последнего обращения
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.updated).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+elapsed*l.limit.RequestsPerSecond)
+	bucket.updated = now
+}
+
+// sweep удаляет полные корзины: для клиента они неотличимы от новых
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateLimitMiddleware ограничивает частоту запросов к группе маршрутов.
+// Ключом служит ID пользователя или API ключа из валидного токена, а без него - IP клиента;
+// поддельный или просроченный токен не дает обойти ограничение по IP
+func rateLimitMiddleware(limit RateLimit, tokenService *auth.TokenService) gin.HandlerFunc {
+	if limit.RequestsPerSecond <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := newRateLimiter(limit)
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if token := c.GetHeader("Authorization"); token != "" && tokenService != nil {
+			if claims, err := tokenService.ParseToken(token); err == nil {
+				key = "user:" + strconv.Itoa(claims.UserID)
+				// Запросы по API ключу учитываются отдельно от запросов создателя ключа
+				if claims.APIKeyID != 0 {
+					key = "api_key:" + strconv.Itoa(claims.APIKeyID)
+				}
+			}
+		}
+
+		if ok, wait := limiter.allow(key, time.Now()); !ok {
+			rejectRateLimited(c, wait)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rejectRateLimited отвечает 429 с заголовком Retry-After и прерывает обработку запроса
+func rejectRateLimited(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	respondError(c, http.StatusTooManyRequests, CodeTooManyRequests)
+	c.Abort()
+}
+
+// retryAfterSeconds округляет ожидание вверх до целых секунд для заголовка Retry-After, но не меньше одной
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -1,17 +1,73 @@
 package api
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/auth"
+	"Avito_task/internal/metrics"
 )
 
+// RouterConfig содержит настройки маршрутизатора
+type RouterConfig struct {
+	// RequestTimeout ограничивает время обработки запроса, если для маршрута не задано иное
+	RequestTimeout time.Duration
+	// RouteTimeouts задает ограничения времени по шаблону маршрута Gin, например "/user_banner"
+	RouteTimeouts map[string]time.Duration
+	// RateLimits задает ограничения частоты запросов по группам маршрутов RateLimitGroup*; группа без записи не ограничена
+	RateLimits map[string]RateLimit
+	// TokenService проверяет токены, по которым запросы пользователя учитываются отдельно от его IP
+	TokenService *auth.TokenService
+	// RequireIfMatch требует заголовок If-Match у PATCH /banner/:id, чтобы изменения не перезаписывали друг друга молча
+	RequireIfMatch bool
+	// TrustedProxies перечисляет прокси, чьим заголовкам X-Forwarded-For можно доверять при определении IP клиента
+	TrustedProxies []string
+}
+
 // SetupRouter настраивает маршруты и возвращает готовый маршрутизатор Gin
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+func SetupRouter(bannerHandlers *BannerHandlers, auditHandlers *AuditHandlers, userHandlers *UserHandlers, apiKeyHandlers *APIKeyHandlers, cfg RouterConfig) (*gin.Engine, error) {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.Use(requestIDMiddleware(), loggingMiddleware(), recoveryMiddleware(), metricsMiddleware())
+	router.Use(timeoutMiddleware(cfg.RequestTimeout, cfg.RouteTimeouts))
+	router.Use(apiKeyMiddleware(apiKeyHandlers.APIKeyUseCase, cfg.RateLimits[RateLimitGroupAPIKey]))
 
 	// Обработчики маршрутов
 	router.GET("/ping", pingHandler)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	login := router.Group("", rateLimitMiddleware(cfg.RateLimits[RateLimitGroupLogin], cfg.TokenService))
+	login.POST("/login", userHandlers.LoginHandler)
+
+	userBanner := router.Group("", rateLimitMiddleware(cfg.RateLimits[RateLimitGroupUserBanner], cfg.TokenService))
+	userBanner.GET("/user_banner", bannerHandlers.GetUserBannerHandler)
+	userBanner.GET("/user_banner/:id", bannerHandlers.GetUserBannerByIDHandler)
+	userBanner.POST("/banner/:id/click", bannerHandlers.ClickBannerHandler)
+
+	admin := router.Group("", rateLimitMiddleware(cfg.RateLimits[RateLimitGroupAdmin], cfg.TokenService))
+	admin.GET("/banner", bannerHandlers.GetAllBannersHandler)
+	admin.POST("/banner", bannerHandlers.CreateBanner)
+	admin.GET("/banner/schedule", bannerHandlers.GetBannerScheduleHandler)
+	admin.GET("/banner/export", bannerHandlers.ExportBannersHandler)
+	admin.POST("/banner/import", bannerHandlers.ImportBannersHandler)
+	admin.PATCH("/banner/:id", ifMatchMiddleware(cfg.RequireIfMatch), bannerHandlers.UpdateBannerHandler)
+	admin.DELETE("/banner/:id", bannerHandlers.DeleteBannerHandler)
+	admin.POST("/banner/:id/restore", bannerHandlers.RestoreBannerHandler)
+	admin.GET("/banner/:id/stats", bannerHandlers.GetBannerStatsHandler)
+	admin.GET("/audit", auditHandlers.GetAuditLogHandler)
+	admin.GET("/roles", userHandlers.GetRolesHandler)
+	admin.PUT("/roles/:name", userHandlers.SaveRoleHandler)
+	admin.GET("/grants", userHandlers.GetGrantsHandler)
+	admin.POST("/grants", userHandlers.CreateGrantHandler)
+	admin.DELETE("/grants/:id", userHandlers.DeleteGrantHandler)
+	admin.GET("/api_keys", apiKeyHandlers.GetAPIKeysHandler)
+	admin.POST("/api_keys", apiKeyHandlers.CreateAPIKeyHandler)
+	admin.DELETE("/api_keys/:id", apiKeyHandlers.DeleteAPIKeyHandler)
 
-	return router
+	return router, nil
 }
 
 func pingHandler(c *gin.Context) {
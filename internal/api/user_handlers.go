@@ -0,0 +1,181 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+// UserHandlers представляет обработчики запросов для пользователей
+type UserHandlers struct {
+	UserUseCase *usecase.UserUseCase
+}
+
+// NewUserHandlers создает новый экземпляр UserHandlers
+func NewUserHandlers(userUseCase *usecase.UserUseCase) *UserHandlers {
+	return &UserHandlers{UserUseCase: userUseCase}
+}
+
+// LoginHandler обработчик для получения токена по имени пользователя и паролю
+func (h *UserHandlers) LoginHandler(c *gin.Context) {
+	var req entity.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token, err := h.UserUseCase.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		var lockedErr *usecase.AccountLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(time.Until(lockedErr.Until))))
+			respondError(c, http.StatusTooManyRequests, CodeAccountLocked)
+		case errors.Is(err, usecase.ErrInvalidCredentials):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// GetRolesHandler обработчик для получения ролей и их разрешений
+func (h *UserHandlers) GetRolesHandler(c *gin.Context) {
+	token := c.GetHeader("Authorization")
+	roles, err := h.UserUseCase.GetRoles(c.Request.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// SaveRoleHandler обработчик для создания роли или замены ее разрешений
+func (h *UserHandlers) SaveRoleHandler(c *gin.Context) {
+	var req entity.SaveRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	role, err := h.UserUseCase.SaveRole(c.Request.Context(), &entity.Role{Name: c.Param("name"), Permissions: req.Permissions}, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// GetGrantsHandler обработчик для получения разрешений на фичи; параметр user_id оставляет разрешения одного пользователя
+func (h *UserHandlers) GetGrantsHandler(c *gin.Context) {
+	userID := 0
+	if value := c.Query("user_id"); value != "" {
+		var err error
+		if userID, err = strconv.Atoi(value); err != nil || userID <= 0 {
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+			return
+		}
+	}
+
+	token := c.GetHeader("Authorization")
+	grants, err := h.UserUseCase.GetGrants(c.Request.Context(), userID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+// CreateGrantHandler обработчик для выдачи пользователю разрешения на фичу
+func (h *UserHandlers) CreateGrantHandler(c *gin.Context) {
+	var req entity.CreateGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	grant, err := h.UserUseCase.CreateGrant(c.Request.Context(), &entity.PermissionGrant{
+		UserID:     req.UserID,
+		Permission: req.Permission,
+		FeatureID:  req.FeatureID,
+	}, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidParams):
+			respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+// DeleteGrantHandler обработчик для отзыва разрешения на фичу
+func (h *UserHandlers) DeleteGrantHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, CodeInvalidParams)
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.UserUseCase.DeleteGrant(c.Request.Context(), id, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnauthorized):
+			respondError(c, http.StatusUnauthorized, CodeUnauthorized)
+		case errors.Is(err, usecase.ErrForbidden):
+			respondError(c, http.StatusForbidden, CodeForbidden)
+		case errors.Is(err, usecase.ErrGrantNotFound):
+			respondError(c, http.StatusNotFound, CodeGrantNotFound)
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+
+	"Avito_task/internal/entity"
 )
 
 // Секретный ключ для подписи токена
@@ -14,9 +16,28 @@ var jwtKey = []byte("akdj2374529asdfbalsjfb3")
 type Claims struct {
 	UserID int    `json:"user_id"`
 	Role   string `json:"role"`
+	// Permissions содержит разрешения роли на момент входа; nil означает токен, выданный до появления разрешений
+	Permissions []string `json:"permissions"`
+	// APIKeyID задан у токенов, выданных на время запроса по API ключу; UserID у них - создатель ключа
+	APIKeyID int `json:"api_key_id,omitempty"`
 	jwt.StandardClaims
 }
 
+// HasPermission сообщает, есть ли у владельца токена разрешение.
+// Токенам без списка разрешений достаются разрешения встроенной роли с тем же именем
+func (c *Claims) HasPermission(permission string) bool {
+	permissions := c.Permissions
+	if permissions == nil {
+		permissions = entity.BuiltinRolePermissions(c.Role)
+	}
+	for _, granted := range permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
 // Генерация JWT токена
 func GenerateToken(userID int, role string) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour) // Токен действителен 24 часа
@@ -7,6 +7,9 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
+// ErrPermissionDenied возвращается, если у валидного токена нет нужного разрешения
+var ErrPermissionDenied = errors.New("у токена нет нужного разрешения")
+
 // TokenService представляет сервис для работы с токенами JWT
 type TokenService struct {
 	jwtKey []byte
@@ -19,16 +22,32 @@ func NewTokenService(jwtKey []byte) *TokenService {
 	}
 }
 
-// GenerateToken генерирует JWT токен
-func (ts *TokenService) GenerateToken(userID int, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Токен действителен 24 часа
+// GenerateToken генерирует JWT токен с ролью пользователя и ее разрешениями на момент входа
+func (ts *TokenService) GenerateToken(userID int, role string, permissions []string) (string, error) {
+	return ts.sign(&Claims{
+		UserID:      userID,
+		Role:        role,
+		Permissions: permissions,
+	}, 24*time.Hour) // Токен действителен 24 часа
+}
+
+// GenerateAPIKeyToken генерирует короткоживущий токен для одного запроса по API ключу: разрешения токена
+// совпадают с разрешениями ключа, а действия записываются от имени создателя ключа с указанием самого ключа
+func (ts *TokenService) GenerateAPIKeyToken(apiKeyID, createdBy int, scopes []string, ttl time.Duration) (string, error) {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return ts.sign(&Claims{
+		UserID:      createdBy,
+		Permissions: scopes,
+		APIKeyID:    apiKeyID,
+	}, ttl)
+}
 
-	claims := &Claims{
-		UserID: userID,
-		Role:   role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+// sign подписывает claims, действующие в течение ttl
+func (ts *TokenService) sign(claims *Claims, ttl time.Duration) (string, error) {
+	claims.StandardClaims = jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(ttl).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -56,16 +75,16 @@ func (ts *TokenService) ParseToken(tokenString string) (*Claims, error) {
 	}
 }
 
-// VerifyAdminToken проверяет, является ли токен админским
-func (ts *TokenService) VerifyAdminToken(tokenString string) error {
+// Authorize разбирает токен и проверяет, что у него есть разрешение permission
+func (ts *TokenService) Authorize(tokenString, permission string) (*Claims, error) {
 	claims, err := ts.ParseToken(tokenString)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if claims.Role != "admin" {
-		return errors.New("требуется администраторский токен")
+	if !claims.HasPermission(permission) {
+		return nil, ErrPermissionDenied
 	}
 
-	return nil
+	return claims, nil
 }
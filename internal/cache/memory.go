@@ -0,0 +1,104 @@
+// Package cache содержит реализации кэша баннеров пользователей: в памяти процесса и в Redis.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+// Проверка того, что реализации кэша удовлетворяют интерфейсу сценариев использования
+var (
+	_ usecase.BannerCache = (*MemoryBannerCache)(nil)
+	_ usecase.BannerCache = (*RedisBannerCache)(nil)
+)
+
+// memoryEntry хранит баннер и момент истечения его срока жизни
+type memoryEntry struct {
+	banner    *entity.Banner
+	expiresAt time.Time
+}
+
+// MemoryBannerCache хранит баннеры пользователей в памяти процесса в течение TTL.
+// Удаление записей видно только этому процессу, поэтому при нескольких репликах нужен RedisBannerCache
+type MemoryBannerCache struct {
+	ttl time.Duration
+
+	mu      sync.RWMutex
+	entries map[usecase.BannerCacheKey]memoryEntry
+}
+
+// NewMemoryBannerCache создает новый экземпляр MemoryBannerCache
+func NewMemoryBannerCache(ttl time.Duration) *MemoryBannerCache {
+	return &MemoryBannerCache{
+		ttl:     ttl,
+		entries: make(map[usecase.BannerCacheKey]memoryEntry),
+	}
+}
+
+// Get возвращает баннер из кэша, если срок его жизни не истек
+func (c *MemoryBannerCache) Get(ctx context.Context, key usecase.BannerCacheKey) (*entity.Banner, bool, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return nil, false, nil
+	}
+	return entry.banner, true, nil
+}
+
+// Set сохраняет баннер в кэш на время TTL
+func (c *MemoryBannerCache) Set(ctx context.Context, key usecase.BannerCacheKey, banner *entity.Banner) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = memoryEntry{
+		banner:    banner,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	return nil
+}
+
+// Invalidate удаляет записи из кэша
+func (c *MemoryBannerCache) Invalidate(ctx context.Context, keys ...usecase.BannerCacheKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+// InvalidateBanner удаляет записи, в которых лежит баннер id, и записи пар (тег, фича), которые он занимает
+// после изменения; используется для изменений, сделанных другой репликой
+func (c *MemoryBannerCache) InvalidateBanner(id, featureID int, tagIDs []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.banner.ID == id {
+			delete(c.entries, key)
+		}
+	}
+	for _, tagID := range tagIDs {
+		delete(c.entries, usecase.BannerCacheKey{TagID: tagID, FeatureID: featureID})
+	}
+}
+
+// Clear удаляет все записи; используется, когда часть событий ленты изменений могла быть потеряна
+func (c *MemoryBannerCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[usecase.BannerCacheKey]memoryEntry)
+}
+
+// TTL возвращает срок жизни записей
+func (c *MemoryBannerCache) TTL() time.Duration {
+	return c.ttl
+}
@@ -0,0 +1,44 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Avito_task/internal/cache"
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+func TestMemoryBannerCacheInvalidateBanner(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := cache.NewMemoryBannerCache(time.Minute)
+	moved := &entity.Banner{ID: 1, FeatureID: 1, TagIDs: []int{1, 2}}
+	other := &entity.Banner{ID: 2, FeatureID: 2, TagIDs: []int{1}}
+	kept := &entity.Banner{ID: 3, FeatureID: 3, TagIDs: []int{1}}
+	for _, banner := range []*entity.Banner{moved, other, kept} {
+		for _, tagID := range banner.TagIDs {
+			if err := memoryCache.Set(ctx, usecase.BannerCacheKey{TagID: tagID, FeatureID: banner.FeatureID}, banner); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Другая реплика перенесла баннер 1 в пару (1, 2): удаляются его прежние записи и запись занятой им пары
+	memoryCache.InvalidateBanner(moved.ID, 2, []int{1})
+
+	checks := []struct {
+		key    usecase.BannerCacheKey
+		cached bool
+	}{
+		{usecase.BannerCacheKey{TagID: 1, FeatureID: 1}, false},
+		{usecase.BannerCacheKey{TagID: 2, FeatureID: 1}, false},
+		{usecase.BannerCacheKey{TagID: 1, FeatureID: 2}, false},
+		{usecase.BannerCacheKey{TagID: 1, FeatureID: 3}, true},
+	}
+	for _, check := range checks {
+		if _, ok, _ := memoryCache.Get(ctx, check.key); ok != check.cached {
+			t.Errorf("запись %+v: ожидалось наличие %v, получено %v", check.key, check.cached, ok)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/usecase"
+)
+
+// RedisBannerCache хранит баннеры пользователей в Redis или совместимом хранилище в виде JSON.
+// Все реплики сервиса работают с одними записями, поэтому изменение баннера на одной реплике сразу видно остальным
+type RedisBannerCache struct {
+	client    redis.UniversalClient
+	ttl       time.Duration
+	keyPrefix string
+}
+
+// NewRedisBannerCache создает новый экземпляр RedisBannerCache; keyPrefix отделяет ключи сервиса от других данных в Redis
+func NewRedisBannerCache(client redis.UniversalClient, ttl time.Duration, keyPrefix string) *RedisBannerCache {
+	return &RedisBannerCache{
+		client:    client,
+		ttl:       ttl,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// redisKey возвращает ключ Redis для пары (тег, фича)
+func (c *RedisBannerCache) redisKey(key usecase.BannerCacheKey) string {
+	return fmt.Sprintf("%suser_banner:%d:%d", c.keyPrefix, key.TagID, key.FeatureID)
+}
+
+// Get возвращает баннер из кэша; срок жизни записей ограничивает сам Redis
+func (c *RedisBannerCache) Get(ctx context.Context, key usecase.BannerCacheKey) (*entity.Banner, bool, error) {
+	data, err := c.client.Get(ctx, c.redisKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	banner := &entity.Banner{}
+	if err := json.Unmarshal(data, banner); err != nil {
+		return nil, false, fmt.Errorf("ошибка разбора баннера из кэша: %w", err)
+	}
+	return banner, true, nil
+}
+
+// Set сохраняет баннер в кэш на время TTL
+func (c *RedisBannerCache) Set(ctx context.Context, key usecase.BannerCacheKey, banner *entity.Banner) error {
+	data, err := json.Marshal(banner)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.redisKey(key), data, c.ttl).Err()
+}
+
+// Invalidate удаляет записи из кэша одной командой DEL
+func (c *RedisBannerCache) Invalidate(ctx context.Context, keys ...usecase.BannerCacheKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = c.redisKey(key)
+	}
+	return c.client.Del(ctx, redisKeys...).Err()
+}
+
+// TTL возвращает срок жизни записей
+func (c *RedisBannerCache) TTL() time.Duration {
+	return c.ttl
+}
@@ -0,0 +1,193 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config представляет настройки сервиса из config.yaml
+type Config struct {
+	Database  DatabaseConfig  `yaml:"database"`
+	Server    ServerConfig    `yaml:"server"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Stats     StatsConfig     `yaml:"stats"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Snapshot  SnapshotConfig  `yaml:"snapshot"`
+	Events    EventsConfig    `yaml:"events"`
+	Retention RetentionConfig `yaml:"retention"`
+	Users     UsersConfig     `yaml:"users"`
+	Logging   LoggingConfig   `yaml:"logging"`
+}
+
+// DatabaseConfig содержит параметры подключения к PostgreSQL
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"dbname"`
+
+	SlowQueryThresholdMs int `yaml:"slow_query_threshold_ms"`
+}
+
+// ServerConfig содержит параметры HTTP сервера
+type ServerConfig struct {
+	Port             int            `yaml:"port"`
+	RequestTimeoutMs int            `yaml:"request_timeout_ms"`
+	RouteTimeoutsMs  map[string]int `yaml:"route_timeouts_ms"`
+	// RateLimits задает ограничения частоты запросов по группам маршрутов: user_banner, admin, login, api_key
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits"`
+	// RequireIfMatch требует заголовок If-Match при изменении баннера
+	RequireIfMatch bool     `yaml:"require_if_match"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// RateLimitConfig содержит параметры корзины токенов для группы маршрутов
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// JWTConfig содержит параметры подписи токенов
+type JWTConfig struct {
+	Secret          string `yaml:"secret"`
+	ExpirationHours int    `yaml:"expiration_hours"`
+}
+
+// SchedulerConfig содержит параметры планировщика окон показа баннеров
+type SchedulerConfig struct {
+	IntervalSeconds int `yaml:"interval_seconds"`
+	HorizonHours    int `yaml:"horizon_hours"`
+}
+
+// StatsConfig содержит параметры сохранения статистики показов и кликов
+type StatsConfig struct {
+	FlushIntervalSeconds int `yaml:"flush_interval_seconds"`
+	BatchSize            int `yaml:"batch_size"`
+}
+
+// CacheConfig содержит параметры кэша баннеров пользователей
+type CacheConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	TTLSeconds int  `yaml:"ttl_seconds"`
+	// Backend выбирает хранилище кэша: memory для одной реплики или redis для нескольких
+	Backend string      `yaml:"backend"`
+	Redis   RedisConfig `yaml:"redis"`
+}
+
+// RedisConfig содержит параметры подключения к Redis или совместимому хранилищу
+type RedisConfig struct {
+	Addr      string `yaml:"addr"`
+	Password  string `yaml:"password"`
+	DB        int    `yaml:"db"`
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+// SnapshotConfig содержит параметры снимка баннеров пользователей в памяти.
+// Включенный снимок загружается при запуске и отвечает на /user_banner без обращения к базе данных
+type SnapshotConfig struct {
+	Enabled                bool `yaml:"enabled"`
+	RefreshIntervalSeconds int  `yaml:"refresh_interval_seconds"`
+}
+
+// EventsConfig содержит параметры ленты изменений баннеров через LISTEN/NOTIFY PostgreSQL.
+// Репозиторий отправляет события всегда, а слушатель нужен, чтобы реплика узнавала об изменениях, сделанных другими
+type EventsConfig struct {
+	Enabled             bool `yaml:"enabled"`
+	MinReconnectSeconds int  `yaml:"min_reconnect_seconds"`
+	MaxReconnectSeconds int  `yaml:"max_reconnect_seconds"`
+}
+
+// RetentionConfig содержит параметры окончательного удаления баннеров, удаленных мягко
+type RetentionConfig struct {
+	DeletedBannerDays int `yaml:"deleted_banner_days"`
+	IntervalMinutes   int `yaml:"interval_minutes"`
+}
+
+// UsersConfig содержит правила паролей и блокировки входа; роли хранятся в базе данных
+type UsersConfig struct {
+	MinPasswordLength  int `yaml:"min_password_length"`
+	MaxFailedLogins    int `yaml:"max_failed_logins"`
+	LockoutBaseSeconds int `yaml:"lockout_base_seconds"`
+	LockoutMaxMinutes  int `yaml:"lockout_max_minutes"`
+}
+
+// LoggingConfig содержит параметры структурированного логирования
+type LoggingConfig struct {
+	Level string `yaml:"level"`
+}
+
+// DSN возвращает строку подключения к базе данных
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		c.Host, c.Port, c.User, c.Password, c.DBName)
+}
+
+// Load читает настройки из YAML файла
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения конфигурации: %w", err)
+	}
+
+	// Значения по умолчанию для необязательных разделов
+	cfg := &Config{
+		Database:  DatabaseConfig{SlowQueryThresholdMs: 200},
+		Server:    ServerConfig{Port: 8080, RequestTimeoutMs: 5000, RequireIfMatch: true},
+		Scheduler: SchedulerConfig{IntervalSeconds: 60, HorizonHours: 24},
+		Stats:     StatsConfig{FlushIntervalSeconds: 10, BatchSize: 1000},
+		Cache: CacheConfig{
+			Enabled:    true,
+			TTLSeconds: 300,
+			Backend:    "memory",
+			Redis:      RedisConfig{Addr: "localhost:6379", KeyPrefix: "banner_service:"},
+		},
+		Snapshot:  SnapshotConfig{RefreshIntervalSeconds: 30},
+		Events:    EventsConfig{Enabled: true, MinReconnectSeconds: 1, MaxReconnectSeconds: 60},
+		Retention: RetentionConfig{DeletedBannerDays: 30, IntervalMinutes: 60},
+		Users: UsersConfig{
+			MinPasswordLength:  8,
+			MaxFailedLogins:    5,
+			LockoutBaseSeconds: 60,
+			LockoutMaxMinutes:  60,
+		},
+		Logging: LoggingConfig{Level: "info"},
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("ошибка разбора конфигурации: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// validate проверяет интервалы фоновых процессов: time.NewTicker не принимает неположительный период
+func (c *Config) validate() error {
+	intervals := []struct {
+		name  string
+		value int
+	}{
+		{"scheduler.interval_seconds", c.Scheduler.IntervalSeconds},
+		{"stats.flush_interval_seconds", c.Stats.FlushIntervalSeconds},
+		{"snapshot.refresh_interval_seconds", c.Snapshot.RefreshIntervalSeconds},
+		{"retention.interval_minutes", c.Retention.IntervalMinutes},
+		{"events.min_reconnect_seconds", c.Events.MinReconnectSeconds},
+		{"events.max_reconnect_seconds", c.Events.MaxReconnectSeconds},
+	}
+	for _, interval := range intervals {
+		if interval.value <= 0 {
+			return fmt.Errorf("%s должен быть положительным, получено %d", interval.name, interval.value)
+		}
+	}
+
+	if c.Events.MaxReconnectSeconds < c.Events.MinReconnectSeconds {
+		return fmt.Errorf("events.max_reconnect_seconds не может быть меньше events.min_reconnect_seconds")
+	}
+
+	return nil
+}
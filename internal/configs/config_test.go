@@ -0,0 +1,41 @@
+package configs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Avito_task/internal/configs"
+)
+
+func TestConfigIntervals(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"defaults", "", true},
+		{"zero scheduler interval", "scheduler:\n  interval_seconds: 0\n", false},
+		{"negative stats flush interval", "stats:\n  flush_interval_seconds: -1\n", false},
+		{"zero snapshot refresh interval", "snapshot:\n  refresh_interval_seconds: 0\n", false},
+		{"zero retention interval", "retention:\n  interval_minutes: 0\n", false},
+		{"reconnect bounds reversed", "events:\n  min_reconnect_seconds: 10\n  max_reconnect_seconds: 5\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := configs.Load(path)
+			if tt.valid && err != nil {
+				t.Fatalf("ожидалась корректная конфигурация, получена ошибка %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("ожидалась ошибка конфигурации")
+			}
+		})
+	}
+}
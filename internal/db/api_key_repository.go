@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"Avito_task/internal/entity"
+)
+
+// APIKeyRepository представляет репозиторий API ключей в базе данных
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository создает новый экземпляр APIKeyRepository
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db}
+}
+
+// apiKeyColumns перечисляет столбцы ключа в порядке scanAPIKey
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_by, created_at`
+
+// scanAPIKey читает ключ из строки результата
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*entity.APIKey, error) {
+	key := &entity.APIKey{}
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedBy, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	return key, nil
+}
+
+// CreateAPIKey сохраняет новый ключ и заполняет его ID и время создания
+func (kr *APIKeyRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) error {
+	defer observeQuery(ctx, "APIKeyRepository", "CreateAPIKey", time.Now())
+
+	return kr.db.QueryRowContext(ctx, `
+        INSERT INTO api_keys (name, prefix, key_hash, scopes, expires_at, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at
+    `, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt, key.CreatedBy).Scan(&key.ID, &key.CreatedAt)
+}
+
+// GetAPIKeyByHash возвращает ключ по хешу его значения
+func (kr *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	defer observeQuery(ctx, "APIKeyRepository", "GetAPIKeyByHash", time.Now())
+
+	return scanAPIKey(kr.db.QueryRowContext(ctx, `
+        SELECT `+apiKeyColumns+`
+        FROM api_keys
+        WHERE key_hash = $1
+    `, keyHash))
+}
+
+// GetAPIKeys возвращает все ключи, упорядоченные по ID
+func (kr *APIKeyRepository) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	defer observeQuery(ctx, "APIKeyRepository", "GetAPIKeys", time.Now())
+
+	rows, err := kr.db.QueryContext(ctx, `
+        SELECT `+apiKeyColumns+`
+        FROM api_keys
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*entity.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey удаляет ключ и возвращает его; для отсутствующего ключа возвращает sql.ErrNoRows
+func (kr *APIKeyRepository) DeleteAPIKey(ctx context.Context, id int) (*entity.APIKey, error) {
+	defer observeQuery(ctx, "APIKeyRepository", "DeleteAPIKey", time.Now())
+
+	return scanAPIKey(kr.db.QueryRowContext(ctx, `
+        DELETE FROM api_keys
+        WHERE id = $1
+        RETURNING `+apiKeyColumns, id))
+}
+
+// TouchAPIKey запоминает время последнего использования ключа
+func (kr *APIKeyRepository) TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error {
+	defer observeQuery(ctx, "APIKeyRepository", "TouchAPIKey", time.Now())
+
+	_, err := kr.db.ExecContext(ctx, `
+        UPDATE api_keys
+        SET last_used_at = $1
+        WHERE id = $2
+    `, usedAt, id)
+	return err
+}
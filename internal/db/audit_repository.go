@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// AuditRepository представляет репозиторий журнала аудита; записи только добавляются
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+// NewAuditRepository создает новый экземпляр AuditRepository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// CreateAuditRecord добавляет запись в журнал аудита и заполняет ее ID и время
+func (repo *AuditRepository) CreateAuditRecord(ctx context.Context, record *entity.AuditRecord) error {
+	defer observeQuery(ctx, "AuditRepository", "CreateAuditRecord", time.Now())
+
+	diff, err := json.Marshal(record.Diff)
+	if err != nil {
+		return err
+	}
+
+	return repo.DB.QueryRowContext(ctx, `
+        INSERT INTO audit_log (actor_id, api_key_id, action, entity, entity_id, diff)
+        VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6)
+        RETURNING id, created_at
+    `, record.ActorID, record.APIKeyID, record.Action, record.Entity, record.EntityID, string(diff)).Scan(&record.ID, &record.CreatedAt)
+}
+
+// GetAuditRecords получает записи журнала аудита по фильтру, начиная с последних
+func (repo *AuditRepository) GetAuditRecords(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditRecord, error) {
+	defer observeQuery(ctx, "AuditRepository", "GetAuditRecords", time.Now())
+
+	query := `
+        SELECT id, actor_id, COALESCE(api_key_id, 0), action, entity, entity_id, diff, created_at
+        FROM audit_log
+        WHERE TRUE
+    `
+	var args []interface{}
+
+	if filter.Entity != "" {
+		args = append(args, filter.Entity)
+		query += fmt.Sprintf(" AND entity = $%d", len(args))
+	}
+	if filter.ActorID != 0 {
+		args = append(args, filter.ActorID)
+		query += fmt.Sprintf(" AND actor_id = $%d", len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*entity.AuditRecord
+	for rows.Next() {
+		record := &entity.AuditRecord{}
+		var diff []byte
+		if err := rows.Scan(&record.ID, &record.ActorID, &record.APIKeyID, &record.Action, &record.Entity, &record.EntityID, &diff, &record.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(diff, &record.Diff); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
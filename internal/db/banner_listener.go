@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"Avito_task/internal/entity"
+)
+
+// listenerPingInterval задает, как часто проверяется соединение слушателя
+const listenerPingInterval = 90 * time.Second
+
+// BannerEventPublisher принимает события изменения баннеров, полученные слушателем
+type BannerEventPublisher interface {
+	Publish(event entity.BannerEvent)
+}
+
+// BannerListener подписывается на канал BannerEventsChannel и передает события изменения баннеров в BannerEventPublisher.
+// pq.Listener сам восстанавливает соединение; события, отправленные во время разрыва, теряются,
+// поэтому после переподключения подписчики получают BannerEventResync
+type BannerListener struct {
+	DSN          string
+	Bus          BannerEventPublisher
+	MinReconnect time.Duration
+	MaxReconnect time.Duration
+}
+
+// NewBannerListener создает новый экземпляр BannerListener
+func NewBannerListener(dsn string, bus BannerEventPublisher) *BannerListener {
+	return &BannerListener{
+		DSN:          dsn,
+		Bus:          bus,
+		MinReconnect: time.Second,
+		MaxReconnect: time.Minute,
+	}
+}
+
+// Run получает события до отмены контекста
+func (l *BannerListener) Run(ctx context.Context) {
+	listener := pq.NewListener(l.DSN, l.MinReconnect, l.MaxReconnect, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnected:
+			slog.Info("лента изменений баннеров подключена", "channel", BannerEventsChannel)
+		case pq.ListenerEventDisconnected:
+			slog.Warn("лента изменений баннеров отключилась", "error", err)
+		case pq.ListenerEventReconnected:
+			slog.Info("лента изменений баннеров переподключена", "channel", BannerEventsChannel)
+		case pq.ListenerEventConnectionAttemptFailed:
+			slog.Warn("ошибка подключения ленты изменений баннеров", "error", err)
+		}
+	})
+	// Проверка соединения идет в отдельной горутине: Ping ждет ответа из того же соединения, что и уведомления,
+	// и при заполненном буфере Notify заблокировал бы цикл, который этот буфер разбирает.
+	// Горутина останавливается до закрытия слушателя, а Run дожидается ее завершения
+	var pinger sync.WaitGroup
+	defer pinger.Wait()
+	defer listener.Close()
+	done := make(chan struct{})
+	defer close(done)
+
+	// Listen ждет соединения, поэтому закрытие слушателя при отмене контекста прерывает и его
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	if err := listener.Listen(BannerEventsChannel); err != nil {
+		if ctx.Err() == nil {
+			slog.Error("ошибка подписки на ленту изменений баннеров", "error", err)
+		}
+		return
+	}
+
+	pinger.Add(1)
+	go func() {
+		defer pinger.Done()
+		ping(listener, done)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			l.handle(notification)
+		}
+	}
+}
+
+// ping проверяет соединение слушателя раз в listenerPingInterval, пока не закрыт done; следующая проверка
+// начинается только после завершения предыдущей. Ошибку Ping не нужно обрабатывать: разорванное соединение
+// слушатель восстанавливает сам
+func ping(listener *pq.Listener, done <-chan struct{}) {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			listener.Ping()
+		}
+	}
+}
+
+// handle передает уведомление в шину событий; nil означает переподключение, после которого нужна полная сверка
+func (l *BannerListener) handle(notification *pq.Notification) {
+	if notification == nil {
+		l.Bus.Publish(entity.BannerEvent{Change: entity.BannerEventResync})
+		return
+	}
+
+	var event entity.BannerEvent
+	if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+		slog.Error("некорректное событие ленты изменений баннеров", "payload", notification.Extra, "error", err)
+		return
+	}
+	l.Bus.Publish(event)
+}
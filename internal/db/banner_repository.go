@@ -1,14 +1,41 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"Avito_task/internal/entity"
 )
 
-// BannerRepository представляет репозиторий для работы с баннерами в базе данных
+// Querier содержит методы выполнения запросов, общие для *sql.DB и *sql.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// BannerEventsChannel является каналом LISTEN/NOTIFY, в который репозиторий баннеров отправляет события изменений
+const BannerEventsChannel = "banner_events"
+
+// transactionKey является ключом контекста, под которым InTransaction передает транзакцию
+type transactionKey struct{}
+
+// maxBannerEventPayload ограничивает размер события: PostgreSQL не принимает в NOTIFY 8000 байт и больше
+const maxBannerEventPayload = 8000
+
+// BannerRepository представляет репозиторий для работы с баннерами в базе данных;
+// каждое изменение выполняется в одной транзакции с NOTIFY в канал BannerEventsChannel
 type BannerRepository struct {
 	DB *sql.DB
+	// Origin попадает в события и позволяет реплике отличить свои изменения от изменений других реплик
+	Origin string
 }
 
 // NewBannerRepository создает новый экземпляр BannerRepository
@@ -17,161 +44,532 @@ func NewBannerRepository(db *sql.DB) *BannerRepository {
 }
 
 // CreateBanner создает новый баннер в базе данных
-func (repo *BannerRepository) CreateBanner(banner *entity.Banner) error {
-	_, err := repo.DB.Exec(`
-        INSERT INTO banners (json_structure, feature_id, is_active)
-        VALUES ($1, $2, $3)
-    `, banner.JSONStructure, banner.FeatureID, banner.IsActive)
-	if err != nil {
-		return err
-	}
+func (repo *BannerRepository) CreateBanner(ctx context.Context, banner *entity.Banner) error {
+	defer observeQuery(ctx, "BannerRepository", "CreateBanner", time.Now())
 
-	// Получение ID созданного баннера
-	err = repo.DB.QueryRow("SELECT lastval()").Scan(&banner.ID)
-	if err != nil {
-		return err
-	}
-
-	// Добавление связей с тегами
-	for _, tagID := range banner.TagIDs {
-		_, err := repo.DB.Exec(`
-            INSERT INTO banner_tags (banner_id, tag_id)
-            VALUES ($1, $2)
-        `, banner.ID, tagID)
+	return repo.InTransaction(ctx, func(ctx context.Context) error {
+		// Получение ID созданного баннера в том же запросе: lastval() может выполниться на другом соединении пула
+		err := repo.conn(ctx).QueryRowContext(ctx, `
+            INSERT INTO banners (json_structure, feature_id, is_active, active_from, active_until)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id, created_at, updated_at, version
+        `, banner.JSONStructure, banner.FeatureID, banner.IsActive, banner.ActiveFrom, banner.ActiveUntil).Scan(&banner.ID, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version)
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
+		// Добавление связей с тегами
+		for _, tagID := range banner.TagIDs {
+			_, err := repo.conn(ctx).ExecContext(ctx, `
+                INSERT INTO banner_tags (banner_id, tag_id)
+                VALUES ($1, $2)
+            `, banner.ID, tagID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Добавление вариантов содержимого
+		if err := repo.insertBannerVariants(ctx, banner); err != nil {
+			return err
+		}
+
+		return repo.notify(ctx, entity.BannerEvent{BannerID: banner.ID, Change: entity.BannerEventCreate, FeatureID: banner.FeatureID, TagIDs: banner.TagIDs})
+	})
 }
 
 // GetBannerByID получает баннер из базы данных по его ID
-func (repo *BannerRepository) GetBannerByID(id int, use_last_revision bool) (*entity.Banner, error) {
+func (repo *BannerRepository) GetBannerByID(ctx context.Context, id int, use_last_revision bool) (*entity.Banner, error) {
+	defer observeQuery(ctx, "BannerRepository", "GetBannerByID", time.Now())
+
 	banner := &entity.Banner{}
 	var err error
 	if use_last_revision {
-		err = repo.DB.QueryRow(`
-            SELECT id, json_structure, feature_id, is_active
+		err = repo.conn(ctx).QueryRowContext(ctx, `
+            SELECT id, json_structure, feature_id, is_active, active_from, active_until, created_at, updated_at, version
             FROM banners
             WHERE id = $1
+            AND deleted_at IS NULL
             AND created_at >= NOW() - interval '5 minutes'
             ORDER BY created_at DESC
             LIMIT 1
-        `, id).Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive)
+        `, id).Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive, &banner.ActiveFrom, &banner.ActiveUntil, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version)
 	} else {
-		err = repo.DB.QueryRow(`
-            SELECT id, json_structure, feature_id, is_active
+		err = repo.conn(ctx).QueryRowContext(ctx, `
+            SELECT id, json_structure, feature_id, is_active, active_from, active_until, created_at, updated_at, version
             FROM banners
             WHERE id = $1
-        `, id).Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive)
+            AND deleted_at IS NULL
+        `, id).Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive, &banner.ActiveFrom, &banner.ActiveUntil, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version)
 	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Получение связанных тегов
-	rows, err := repo.DB.Query(`
+	banner.TagIDs, err = repo.getBannerTagIDs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Получение вариантов содержимого
+	banner.Variants, err = repo.getBannerVariants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return banner, nil
+}
+
+// GetUserBanner получает баннер по тегу и фиче, окно показа которого включает момент now
+func (repo *BannerRepository) GetUserBanner(ctx context.Context, tagID, featureID int, now time.Time) (*entity.Banner, error) {
+	defer observeQuery(ctx, "BannerRepository", "GetUserBanner", time.Now())
+
+	banner := &entity.Banner{}
+	err := repo.conn(ctx).QueryRowContext(ctx, `
+        SELECT b.id, b.json_structure, b.feature_id, b.is_active, b.active_from, b.active_until, b.created_at, b.updated_at, b.version
+        FROM banners b
+        JOIN banner_tags bt ON bt.banner_id = b.id
+        WHERE bt.tag_id = $1
+        AND b.feature_id = $2
+        AND b.deleted_at IS NULL
+        AND (b.active_from IS NULL OR b.active_from <= $3)
+        AND (b.active_until IS NULL OR b.active_until > $3)
+        ORDER BY b.is_active DESC, b.id DESC
+        LIMIT 1
+    `, tagID, featureID, now).Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive, &banner.ActiveFrom, &banner.ActiveUntil, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	banner.TagIDs, err = repo.getBannerTagIDs(ctx, banner.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	banner.Variants, err = repo.getBannerVariants(ctx, banner.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return banner, nil
+}
+
+// GetBannerByFeatureAndTags получает неудаленный баннер фичи, набор тегов которого совпадает с tagIDs;
+// из нескольких таких баннеров выбирается баннер с меньшим ID
+func (repo *BannerRepository) GetBannerByFeatureAndTags(ctx context.Context, featureID int, tagIDs []int) (*entity.Banner, error) {
+	defer observeQuery(ctx, "BannerRepository", "GetBannerByFeatureAndTags", time.Now())
+
+	var id int
+	err := repo.conn(ctx).QueryRowContext(ctx, `
+        SELECT b.id
+        FROM banners b
+        WHERE b.feature_id = $1
+        AND b.deleted_at IS NULL
+        AND ARRAY(SELECT bt.tag_id FROM banner_tags bt WHERE bt.banner_id = b.id ORDER BY bt.tag_id)
+            = ARRAY(SELECT t FROM unnest($2::int[]) AS t ORDER BY t)
+        ORDER BY b.id
+        LIMIT 1
+    `, featureID, pq.Array(tagIDs)).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.GetBannerByID(ctx, id, false)
+}
+
+// GetScheduledBanners получает баннеры, окно показа которых начинается или заканчивается в интервале (from, to]
+func (repo *BannerRepository) GetScheduledBanners(ctx context.Context, from, to time.Time) ([]*entity.Banner, error) {
+	defer observeQuery(ctx, "BannerRepository", "GetScheduledBanners", time.Now())
+
+	rows, err := repo.conn(ctx).QueryContext(ctx, `
+        SELECT id, json_structure, feature_id, is_active, active_from, active_until, created_at, updated_at, version
+        FROM banners
+        WHERE deleted_at IS NULL
+        AND ((active_from > $1 AND active_from <= $2)
+        OR (active_until > $1 AND active_until <= $2))
+        ORDER BY id
+    `, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var banners []*entity.Banner
+	for rows.Next() {
+		banner := &entity.Banner{}
+		if err := rows.Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive, &banner.ActiveFrom, &banner.ActiveUntil, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version); err != nil {
+			return nil, err
+		}
+		banners = append(banners, banner)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, banner := range banners {
+		banner.TagIDs, err = repo.getBannerTagIDs(ctx, banner.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return banners, nil
+}
+
+// GetSnapshotBanners получает все неудаленные баннеры вместе с тегами и вариантами содержимого.
+// Баннеры читаются одним запросом, поэтому результат согласован и не содержит половины параллельного изменения
+func (repo *BannerRepository) GetSnapshotBanners(ctx context.Context) ([]*entity.Banner, error) {
+	defer observeQuery(ctx, "BannerRepository", "GetSnapshotBanners", time.Now())
+
+	rows, err := repo.conn(ctx).QueryContext(ctx, `
+        SELECT b.id, b.json_structure, b.feature_id, b.is_active, b.active_from, b.active_until, b.created_at, b.updated_at, b.version,
+            COALESCE((SELECT array_agg(bt.tag_id ORDER BY bt.tag_id) FROM banner_tags bt WHERE bt.banner_id = b.id), '{}'),
+            COALESCE((SELECT json_agg(json_build_object('variant_id', bv.id, 'json_structure', bv.json_structure, 'weight', bv.weight) ORDER BY bv.id)
+                FROM banner_variants bv WHERE bv.banner_id = b.id), '[]')
+        FROM banners b
+        WHERE b.deleted_at IS NULL
+        ORDER BY b.id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var banners []*entity.Banner
+	for rows.Next() {
+		banner := &entity.Banner{}
+		var tagIDs pq.Int64Array
+		var variants []byte
+		if err := rows.Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive, &banner.ActiveFrom, &banner.ActiveUntil, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version, &tagIDs, &variants); err != nil {
+			return nil, err
+		}
+		for _, tagID := range tagIDs {
+			banner.TagIDs = append(banner.TagIDs, int(tagID))
+		}
+		if err := json.Unmarshal(variants, &banner.Variants); err != nil {
+			return nil, fmt.Errorf("ошибка разбора вариантов баннера %d: %w", banner.ID, err)
+		}
+		if len(banner.Variants) == 0 {
+			banner.Variants = nil
+		}
+		banners = append(banners, banner)
+	}
+
+	return banners, rows.Err()
+}
+
+// getBannerTagIDs получает идентификаторы тегов, связанных с баннером
+func (repo *BannerRepository) getBannerTagIDs(ctx context.Context, bannerID int) ([]int, error) {
+	rows, err := repo.conn(ctx).QueryContext(ctx, `
         SELECT tag_id
         FROM banner_tags
         WHERE banner_id = $1
-    `, id)
+    `, bannerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var tagIDs []int
 	for rows.Next() {
 		var tagID int
 		if err := rows.Scan(&tagID); err != nil {
 			return nil, err
 		}
-		banner.TagIDs = append(banner.TagIDs, tagID)
+		tagIDs = append(tagIDs, tagID)
 	}
 
-	return banner, nil
+	return tagIDs, rows.Err()
 }
 
-// UpdateBanner обновляет информацию о баннере в базе данных
-func (repo *BannerRepository) UpdateBanner(banner *entity.Banner) error {
-	_, err := repo.DB.Exec(`
-        UPDATE banners
-        SET json_structure = $1, feature_id = $2, is_active = $3
-        WHERE id = $4
-    `, banner.JSONStructure, banner.FeatureID, banner.IsActive, banner.ID)
-	if err != nil {
-		return err
-	}
+// UpdateBanner обновляет информацию о баннере в базе данных и увеличивает его версию.
+// Если banner.Version не равна нулю, баннер обновляется только в этой версии, иначе возвращается entity.ErrVersionConflict
+func (repo *BannerRepository) UpdateBanner(ctx context.Context, banner *entity.Banner) error {
+	defer observeQuery(ctx, "BannerRepository", "UpdateBanner", time.Now())
 
-	// Удаление старых связей с тегами
-	_, err = repo.DB.Exec(`
-        DELETE FROM banner_tags
-        WHERE banner_id = $1
-    `, banner.ID)
+	return repo.InTransaction(ctx, func(ctx context.Context) error {
+		err := repo.conn(ctx).QueryRowContext(ctx, `
+            UPDATE banners
+            SET json_structure = $1, feature_id = $2, is_active = $3, active_from = $4, active_until = $5, updated_at = NOW(), version = version + 1
+            WHERE id = $6 AND deleted_at IS NULL AND ($7 = 0 OR version = $7)
+            RETURNING created_at, updated_at, version
+        `, banner.JSONStructure, banner.FeatureID, banner.IsActive, banner.ActiveFrom, banner.ActiveUntil, banner.ID, banner.Version).Scan(&banner.CreatedAt, &banner.UpdatedAt, &banner.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			if banner.Version == 0 {
+				// Отсутствующий баннер не считается ошибкой, связи для него не создаются
+				return nil
+			}
+			return repo.versionConflict(ctx, banner.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Удаление старых связей с тегами
+		_, err = repo.conn(ctx).ExecContext(ctx, `
+            DELETE FROM banner_tags
+            WHERE banner_id = $1
+        `, banner.ID)
+		if err != nil {
+			return err
+		}
+
+		// Удаление старых вариантов содержимого
+		_, err = repo.conn(ctx).ExecContext(ctx, `
+            DELETE FROM banner_variants
+            WHERE banner_id = $1
+        `, banner.ID)
+		if err != nil {
+			return err
+		}
+
+		// Добавление новых связей с тегами
+		for _, tagID := range banner.TagIDs {
+			_, err := repo.conn(ctx).ExecContext(ctx, `
+                INSERT INTO banner_tags (banner_id, tag_id)
+                VALUES ($1, $2)
+            `, banner.ID, tagID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Добавление вариантов содержимого
+		if err := repo.insertBannerVariants(ctx, banner); err != nil {
+			return err
+		}
+
+		return repo.notify(ctx, entity.BannerEvent{BannerID: banner.ID, Change: entity.BannerEventUpdate, FeatureID: banner.FeatureID, TagIDs: banner.TagIDs})
+	})
+}
+
+// versionConflict объясняет, почему не обновился баннер с заданной версией: отсутствующий баннер,
+// как и при обновлении без версии, не считается ошибкой, а существующий изменился раньше
+func (repo *BannerRepository) versionConflict(ctx context.Context, id int) error {
+	var exists bool
+	err := repo.conn(ctx).QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM banners WHERE id = $1 AND deleted_at IS NULL)
+    `, id).Scan(&exists)
 	if err != nil {
 		return err
 	}
+	if exists {
+		return entity.ErrVersionConflict
+	}
+	return nil
+}
+
+// DeleteBannerByID помечает баннер удаленным; теги и варианты сохраняются до окончательного удаления
+func (repo *BannerRepository) DeleteBannerByID(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "BannerRepository", "DeleteBannerByID", time.Now())
+
+	return repo.InTransaction(ctx, func(ctx context.Context) error {
+		result, err := repo.conn(ctx).ExecContext(ctx, `
+            UPDATE banners
+            SET deleted_at = NOW()
+            WHERE id = $1 AND deleted_at IS NULL
+        `, id)
+		if err != nil {
+			return err
+		}
 
-	// Добавление новых связей с тегами
-	for _, tagID := range banner.TagIDs {
-		_, err := repo.DB.Exec(`
-            INSERT INTO banner_tags (banner_id, tag_id)
-            VALUES ($1, $2)
-        `, banner.ID, tagID)
+		deleted, err := result.RowsAffected()
 		if err != nil {
 			return err
 		}
+		if deleted == 0 {
+			return nil
+		}
+
+		return repo.notify(ctx, entity.BannerEvent{BannerID: id, Change: entity.BannerEventDelete})
+	})
+}
+
+// RestoreBanner снимает с баннера пометку об удалении; если удаленного баннера нет, возвращает sql.ErrNoRows
+func (repo *BannerRepository) RestoreBanner(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "BannerRepository", "RestoreBanner", time.Now())
+
+	return repo.InTransaction(ctx, func(ctx context.Context) error {
+		event := entity.BannerEvent{BannerID: id, Change: entity.BannerEventRestore}
+		err := repo.conn(ctx).QueryRowContext(ctx, `
+            UPDATE banners
+            SET deleted_at = NULL, updated_at = NOW()
+            WHERE id = $1 AND deleted_at IS NOT NULL
+            RETURNING feature_id
+        `, id).Scan(&event.FeatureID)
+		if err != nil {
+			return err
+		}
+
+		event.TagIDs, err = repo.getBannerTagIDs(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return repo.notify(ctx, event)
+	})
+}
+
+// PurgeDeletedBanners окончательно удаляет баннеры, помеченные удаленными раньше момента before, вместе с тегами и вариантами
+func (repo *BannerRepository) PurgeDeletedBanners(ctx context.Context, before time.Time) (int, error) {
+	defer observeQuery(ctx, "BannerRepository", "PurgeDeletedBanners", time.Now())
+
+	var purged []int
+	err := repo.InTransaction(ctx, func(ctx context.Context) error {
+		rows, err := repo.conn(ctx).QueryContext(ctx, `
+            WITH purged AS (
+                DELETE FROM banners
+                WHERE deleted_at < $1
+                RETURNING id
+            ), purged_tags AS (
+                DELETE FROM banner_tags
+                WHERE banner_id IN (SELECT id FROM purged)
+            ), purged_variants AS (
+                DELETE FROM banner_variants
+                WHERE banner_id IN (SELECT id FROM purged)
+            )
+            SELECT id FROM purged
+        `, before)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			purged = append(purged, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// События отправляются после чтения всех строк: внутри транзакции нельзя выполнять запросы, пока открыт результат
+		for _, id := range purged {
+			if err := repo.notify(ctx, entity.BannerEvent{BannerID: id, Change: entity.BannerEventPurge}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return len(purged), nil
 }
 
-// DeleteBannerByID удаляет баннер из базы данных по его ID
-func (repo *BannerRepository) DeleteBannerByID(id int) error {
-	_, err := repo.DB.Exec(`
-        DELETE FROM banners
-        WHERE id = $1
-    `, id)
+// InTransaction выполняет fn в транзакции: вызовы репозитория с контекстом fn работают внутри нее, а ошибка fn откатывает ее.
+// Изменения баннеров выполняются так же, поэтому изменение и его событие фиксируются или откатываются вместе
+func (repo *BannerRepository) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(transactionKey{}).(*sql.Tx); ok {
+		// Вызов уже выполняется внутри транзакции
+		return fn(ctx)
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	// Удаление связей с тегами
-	_, err = repo.DB.Exec(`
-        DELETE FROM banner_tags
-        WHERE banner_id = $1
-    `, id)
+	if err := fn(context.WithValue(ctx, transactionKey{}, tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// conn возвращает транзакцию из контекста, если вызов выполняется внутри InTransaction, иначе пул соединений
+func (repo *BannerRepository) conn(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(transactionKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return repo.DB
+}
+
+// notify отправляет событие изменения баннера в канал BannerEventsChannel; внутри транзакции PostgreSQL доставляет его после фиксации
+func (repo *BannerRepository) notify(ctx context.Context, event entity.BannerEvent) error {
+	event.Origin = repo.Origin
+	payload, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
+	if len(payload) >= maxBannerEventPayload {
+		// Пары баннера со множеством тегов не помещаются в событие; подписчики обходятся ID баннера
+		event.FeatureID, event.TagIDs = 0, nil
+		if payload, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	_, err = repo.conn(ctx).ExecContext(ctx, `SELECT pg_notify($1, $2)`, BannerEventsChannel, string(payload))
+	return err
 }
 
-// GetAllBanners получает все баннеры с учетом фильтров по фиче, тегу, лимиту и оффсету
-func (repo *BannerRepository) GetAllBanners(tagID, featureID, limit, offset int) ([]*entity.Banner, error) {
-	// Формируем SQL-запрос с учетом фильтров, лимита и оффсета
-	query := `
-        SELECT id, json_structure, feature_id, is_active
-        FROM banners
-        WHERE 1=1
-    `
-	args := []interface{}{}
+// bannerSortColumns сопоставляет поля сортировки списка баннеров с колонками таблицы
+var bannerSortColumns = map[string]string{
+	entity.BannerSortID:        "id",
+	entity.BannerSortCreatedAt: "created_at",
+	entity.BannerSortUpdatedAt: "updated_at",
+}
 
-	// Добавляем условия фильтров, если они указаны
-	if tagID != 0 {
-		query += " AND id IN (SELECT banner_id FROM banner_tags WHERE tag_id = $1)"
-		args = append(args, tagID)
+// GetAllBanners получает страницу баннеров с учетом фильтров, сортировки и курсора
+func (repo *BannerRepository) GetAllBanners(ctx context.Context, query entity.BannerListQuery) ([]*entity.Banner, error) {
+	defer observeQuery(ctx, "BannerRepository", "GetAllBanners", time.Now())
+
+	sort := query.Sort
+	if sort == "" {
+		sort = entity.BannerSortID
 	}
-	if featureID != 0 {
-		query += " AND feature_id = $2"
-		args = append(args, featureID)
+	column, ok := bannerSortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("неизвестное поле сортировки %q", query.Sort)
 	}
 
-	query += " ORDER BY id LIMIT $3 OFFSET $4"
+	where, args := bannerFilter(query.BannerFilter)
+
+	// Keyset-пагинация: продолжаем после последнего баннера предыдущей страницы
+	op, direction := ">", "ASC"
+	if query.Desc {
+		op, direction = "<", "DESC"
+	}
+	if query.After != nil {
+		if column == "id" {
+			args = append(args, query.After.ID)
+			where += fmt.Sprintf(" AND id %s $%d", op, len(args))
+		} else {
+			args = append(args, query.After.At, query.After.ID)
+			where += fmt.Sprintf(" AND (%s, id) %s ($%d, $%d)", column, op, len(args)-1, len(args))
+		}
+	}
 
-	// Выполняем запрос и получаем результат
-	rows, err := repo.DB.Query(query, args...)
+	orderBy := "id " + direction
+	if column != "id" {
+		orderBy = column + " " + direction + ", " + orderBy
+	}
+
+	args = append(args, query.Limit)
+	sqlQuery := fmt.Sprintf(`
+        SELECT id, json_structure, feature_id, is_active, active_from, active_until, created_at, updated_at, version
+        FROM banners
+        WHERE %s
+        ORDER BY %s
+        LIMIT $%d
+    `, where, orderBy, len(args))
+	if query.Offset > 0 {
+		args = append(args, query.Offset)
+		sqlQuery += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := repo.conn(ctx).QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -180,11 +578,116 @@ func (repo *BannerRepository) GetAllBanners(tagID, featureID, limit, offset int)
 	var banners []*entity.Banner
 	for rows.Next() {
 		banner := &entity.Banner{}
-		if err := rows.Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive); err != nil {
+		if err := rows.Scan(&banner.ID, &banner.JSONStructure, &banner.FeatureID, &banner.IsActive, &banner.ActiveFrom, &banner.ActiveUntil, &banner.CreatedAt, &banner.UpdatedAt, &banner.Version); err != nil {
 			return nil, err
 		}
 		banners = append(banners, banner)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Получение связанных тегов
+	for _, banner := range banners {
+		banner.TagIDs, err = repo.getBannerTagIDs(ctx, banner.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return banners, nil
 }
+
+// CountBanners считает баннеры, удовлетворяющие фильтрам
+func (repo *BannerRepository) CountBanners(ctx context.Context, filter entity.BannerFilter) (int, error) {
+	defer observeQuery(ctx, "BannerRepository", "CountBanners", time.Now())
+
+	where, args := bannerFilter(filter)
+
+	var total int
+	err := repo.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM banners WHERE "+where, args...).Scan(&total)
+	return total, err
+}
+
+// bannerFilter формирует условие WHERE и его аргументы для фильтров списка баннеров; удаленные баннеры не попадают в выборку
+func bannerFilter(filter entity.BannerFilter) (string, []interface{}) {
+	where := "deleted_at IS NULL"
+	var args []interface{}
+
+	if filter.TagID != 0 {
+		args = append(args, filter.TagID)
+		where += fmt.Sprintf(" AND id IN (SELECT banner_id FROM banner_tags WHERE tag_id = $%d)", len(args))
+	}
+	if filter.FeatureID != 0 {
+		args = append(args, filter.FeatureID)
+		where += fmt.Sprintf(" AND feature_id = $%d", len(args))
+	}
+
+	// Выражение совпадает с выражением индекса banners_content_search_idx
+	if filter.Search != "" {
+		args = append(args, filter.Search)
+		where += fmt.Sprintf(` AND jsonb_to_tsvector('simple', json_structure, '["string"]') @@ plainto_tsquery('simple', $%d)`, len(args))
+	}
+
+	for _, content := range filter.Content {
+		args = append(args, pq.Array(content.Path))
+		path := len(args)
+		switch content.Op {
+		case entity.ContentFilterContains:
+			args = append(args, likePattern(content.Value))
+			where += fmt.Sprintf(` AND json_structure #>> $%d ILIKE $%d`, path, len(args))
+		default:
+			args = append(args, content.Value)
+			where += fmt.Sprintf(` AND json_structure #>> $%d = $%d`, path, len(args))
+		}
+	}
+
+	return where, args
+}
+
+// likePattern превращает строку в шаблон ILIKE для поиска подстроки, экранируя спецсимволы
+func likePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
+
+// insertBannerVariants сохраняет варианты содержимого баннера и заполняет их ID
+func (repo *BannerRepository) insertBannerVariants(ctx context.Context, banner *entity.Banner) error {
+	for i := range banner.Variants {
+		err := repo.conn(ctx).QueryRowContext(ctx, `
+            INSERT INTO banner_variants (banner_id, json_structure, weight)
+            VALUES ($1, $2, $3)
+            RETURNING id
+        `, banner.ID, banner.Variants[i].JSONStructure, banner.Variants[i].Weight).Scan(&banner.Variants[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getBannerVariants получает варианты содержимого баннера в порядке их создания
+func (repo *BannerRepository) getBannerVariants(ctx context.Context, bannerID int) ([]entity.BannerVariant, error) {
+	rows, err := repo.conn(ctx).QueryContext(ctx, `
+        SELECT id, json_structure, weight
+        FROM banner_variants
+        WHERE banner_id = $1
+        ORDER BY id
+    `, bannerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []entity.BannerVariant
+	for rows.Next() {
+		var variant entity.BannerVariant
+		if err := rows.Scan(&variant.ID, &variant.JSONStructure, &variant.Weight); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
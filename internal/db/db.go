@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	"Avito_task/internal/entity"
 )
 
 // DBManager управляет базой данных.
@@ -19,24 +21,179 @@ func NewDBManager(db *sql.DB) *DBManager {
 
 // SetupTables создает необходимые таблицы в базе данных.
 func (mgr *DBManager) SetupTables() error {
-	// Создание таблицы пользователей
-	_, err := mgr.db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			username TEXT,
-			password_hash TEXT,
-			role TEXT
-		)
-	`)
-	if err != nil {
+	// Создание таблиц пользователей и ролей
+	for _, query := range userSchema {
+		if _, err := mgr.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	if err := mgr.seedRoles(); err != nil {
 		return err
 	}
 
-	// Другие таблицы и соответствующие запросы...
+	// Создание таблиц фич, тегов и баннеров
+	for _, query := range bannerSchema {
+		if _, err := mgr.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	// Создание журнала аудита
+	for _, query := range auditSchema {
+		if _, err := mgr.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
+// seedRoles добавляет встроенные роли; измененные в таблице роли не трогает,
+// кроме роли администратора, которая всегда получает все известные разрешения
+func (mgr *DBManager) seedRoles() error {
+	for _, role := range entity.BuiltinRoles {
+		conflict := "DO NOTHING"
+		if role.Name == entity.RoleAdmin {
+			conflict = "DO UPDATE SET permissions = EXCLUDED.permissions"
+		}
+		_, err := mgr.db.Exec(`
+			INSERT INTO roles (name, permissions)
+			VALUES ($1, $2)
+			ON CONFLICT (name) `+conflict, role.Name, pq.Array(role.Permissions))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// userSchema содержит запросы для создания таблицы пользователей
+var userSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		username TEXT,
+		password_hash TEXT,
+		role TEXT
+	)`,
+	// Счетчик неудачных попыток входа подряд и блокировка входа после них
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ`,
+	// Имена пользователей уникальны; дубликаты, созданные до индекса, переименовываются в "<имя>#<id>",
+	// а имя сохраняет пользователь с наименьшим ID
+	`UPDATE users u
+	SET username = u.username || '#' || u.id
+	WHERE EXISTS (SELECT 1 FROM users d WHERE d.username = u.username AND d.id < u.id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS users_username_idx ON users (username)`,
+	// Роли и их разрешения; встроенные роли добавляет seedRoles
+	`CREATE TABLE IF NOT EXISTS roles (
+		name TEXT PRIMARY KEY,
+		permissions TEXT[] NOT NULL DEFAULT '{}'
+	)`,
+	// Разрешения на отдельные фичи; удаляются вместе с пользователем
+	`CREATE TABLE IF NOT EXISTS permission_grants (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		permission TEXT NOT NULL,
+		feature_id INTEGER NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (user_id, permission, feature_id)
+	)`,
+	// API ключи сервисов; хранится только SHA-256 хеш ключа
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		expires_at TIMESTAMPTZ,
+		last_used_at TIMESTAMPTZ,
+		created_by INTEGER NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+}
+
+// bannerSchema содержит запросы для создания таблиц баннеров и связанных сущностей
+var bannerSchema = []string{
+	`CREATE TABLE IF NOT EXISTS features (
+		id SERIAL PRIMARY KEY,
+		name TEXT
+	)`,
+	`CREATE TABLE IF NOT EXISTS tags (
+		id SERIAL PRIMARY KEY,
+		name TEXT
+	)`,
+	`CREATE TABLE IF NOT EXISTS banners (
+		id SERIAL PRIMARY KEY,
+		json_structure JSONB NOT NULL,
+		feature_id INTEGER NOT NULL,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`ALTER TABLE banners
+		ADD COLUMN IF NOT EXISTS active_from TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS active_until TIMESTAMPTZ`,
+	// Таблицы, созданные до перехода на JSONB, хранят содержимое в TEXT
+	`DO $$
+	BEGIN
+		IF (SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'banners' AND column_name = 'json_structure') = 'text' THEN
+			ALTER TABLE banners ALTER COLUMN json_structure TYPE JSONB USING json_structure::jsonb;
+		END IF;
+	END $$`,
+	`CREATE INDEX IF NOT EXISTS banners_content_search_idx ON banners
+		USING GIN (jsonb_to_tsvector('simple', json_structure, '["string"]'))`,
+	`ALTER TABLE banners ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+	// Версия баннера для оптимистичной блокировки изменений
+	`ALTER TABLE banners ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1`,
+	`CREATE INDEX IF NOT EXISTS banners_deleted_at_idx ON banners (deleted_at) WHERE deleted_at IS NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS banners_feature_id_idx ON banners (feature_id, id)`,
+	`CREATE INDEX IF NOT EXISTS banners_created_at_idx ON banners (created_at, id)`,
+	`CREATE INDEX IF NOT EXISTS banners_updated_at_idx ON banners (updated_at, id)`,
+	`CREATE TABLE IF NOT EXISTS banner_tags (
+		banner_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (banner_id, tag_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS banner_tags_tag_id_idx ON banner_tags (tag_id)`,
+	`CREATE TABLE IF NOT EXISTS banner_variants (
+		id SERIAL PRIMARY KEY,
+		banner_id INTEGER NOT NULL,
+		json_structure TEXT NOT NULL,
+		weight INTEGER NOT NULL CHECK (weight > 0)
+	)`,
+	`CREATE INDEX IF NOT EXISTS banner_variants_banner_id_idx ON banner_variants (banner_id)`,
+	`CREATE TABLE IF NOT EXISTS banner_stats (
+		day DATE NOT NULL,
+		banner_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		feature_id INTEGER NOT NULL,
+		impressions BIGINT NOT NULL DEFAULT 0,
+		clicks BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, banner_id, tag_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS banner_stats_banner_id_idx ON banner_stats (banner_id, day)`,
+}
+
+// auditSchema содержит запросы для создания журнала аудита; правила запрещают изменять и удалять записи
+var auditSchema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		actor_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		diff JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	// API ключ, по которому выполнено действие; у действий по токену пользователя не задан
+	`ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS api_key_id INTEGER`,
+	`CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, created_at)`,
+	`CREATE INDEX IF NOT EXISTS audit_log_actor_id_idx ON audit_log (actor_id, created_at)`,
+	`CREATE OR REPLACE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING`,
+	`CREATE OR REPLACE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING`,
+}
+
 func main() {
 	// Подключение к базе данных PostgreSQL
 	db, err := sql.Open("postgres", "user=youruser dbname=yourdb password=yourpassword sslmode=disable")
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"Avito_task/internal/entity"
 )
@@ -17,8 +19,10 @@ func NewFeatureRepository(db *sql.DB) *FeatureRepository {
 }
 
 // Метод для создания новой фичи
-func (fr *FeatureRepository) CreateFeature(feature *entity.Feature) error {
-	_, err := fr.DB.Exec(`
+func (fr *FeatureRepository) CreateFeature(ctx context.Context, feature *entity.Feature) error {
+	defer observeQuery(ctx, "FeatureRepository", "CreateFeature", time.Now())
+
+	_, err := fr.DB.ExecContext(ctx, `
         INSERT INTO features (name)
         VALUES ($1)
     `, feature.Name)
@@ -27,7 +31,7 @@ func (fr *FeatureRepository) CreateFeature(feature *entity.Feature) error {
 	}
 
 	// Получение ID созданной фичи
-	err = fr.DB.QueryRow("SELECT lastval()").Scan(&feature.ID)
+	err = fr.DB.QueryRowContext(ctx, "SELECT lastval()").Scan(&feature.ID)
 	if err != nil {
 		return err
 	}
@@ -36,9 +40,11 @@ func (fr *FeatureRepository) CreateFeature(feature *entity.Feature) error {
 }
 
 // Метод для получения фичи по ID
-func (fr *FeatureRepository) GetFeatureByID(id int) (*entity.Feature, error) {
+func (fr *FeatureRepository) GetFeatureByID(ctx context.Context, id int) (*entity.Feature, error) {
+	defer observeQuery(ctx, "FeatureRepository", "GetFeatureByID", time.Now())
+
 	feature := &entity.Feature{}
-	err := fr.DB.QueryRow(`
+	err := fr.DB.QueryRowContext(ctx, `
 		SELECT id, name
 		FROM features
 		WHERE id = $1
@@ -51,8 +57,10 @@ func (fr *FeatureRepository) GetFeatureByID(id int) (*entity.Feature, error) {
 }
 
 // Метод для обновления фичи
-func (fr *FeatureRepository) UpdateFeature(id int, newName string) error {
-	_, err := fr.DB.Exec(`
+func (fr *FeatureRepository) UpdateFeature(ctx context.Context, id int, newName string) error {
+	defer observeQuery(ctx, "FeatureRepository", "UpdateFeature", time.Now())
+
+	_, err := fr.DB.ExecContext(ctx, `
 		UPDATE features
 		SET name = $1
 		WHERE id = $2
@@ -65,8 +73,10 @@ func (fr *FeatureRepository) UpdateFeature(id int, newName string) error {
 }
 
 // Метод для удаления фичи по ID
-func (fr *FeatureRepository) DeleteFeatureByID(id int) error {
-	_, err := fr.DB.Exec(`
+func (fr *FeatureRepository) DeleteFeatureByID(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "FeatureRepository", "DeleteFeatureByID", time.Now())
+
+	_, err := fr.DB.ExecContext(ctx, `
 		DELETE FROM features
 		WHERE id = $1
 	`, id)
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// GrantRepository представляет репозиторий разрешений пользователей на отдельные фичи
+type GrantRepository struct {
+	db *sql.DB
+}
+
+// NewGrantRepository создает новый экземпляр GrantRepository
+func NewGrantRepository(db *sql.DB) *GrantRepository {
+	return &GrantRepository{db}
+}
+
+// CreateGrant выдает разрешение и заполняет его ID и время; повторная выдача возвращает существующую запись
+func (gr *GrantRepository) CreateGrant(ctx context.Context, grant *entity.PermissionGrant) error {
+	defer observeQuery(ctx, "GrantRepository", "CreateGrant", time.Now())
+
+	return gr.db.QueryRowContext(ctx, `
+        INSERT INTO permission_grants (user_id, permission, feature_id)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, permission, feature_id) DO UPDATE SET permission = EXCLUDED.permission
+        RETURNING id, created_at
+    `, grant.UserID, grant.Permission, grant.FeatureID).Scan(&grant.ID, &grant.CreatedAt)
+}
+
+// GetGrants возвращает разрешения пользователя userID или, при userID == 0, всех пользователей
+func (gr *GrantRepository) GetGrants(ctx context.Context, userID int) ([]*entity.PermissionGrant, error) {
+	defer observeQuery(ctx, "GrantRepository", "GetGrants", time.Now())
+
+	rows, err := gr.db.QueryContext(ctx, `
+        SELECT id, user_id, permission, feature_id, created_at
+        FROM permission_grants
+        WHERE $1 = 0 OR user_id = $1
+        ORDER BY id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []*entity.PermissionGrant{}
+	for rows.Next() {
+		grant := &entity.PermissionGrant{}
+		if err := rows.Scan(&grant.ID, &grant.UserID, &grant.Permission, &grant.FeatureID, &grant.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// DeleteGrant отзывает разрешение и возвращает его; для отсутствующего разрешения возвращает sql.ErrNoRows
+func (gr *GrantRepository) DeleteGrant(ctx context.Context, id int) (*entity.PermissionGrant, error) {
+	defer observeQuery(ctx, "GrantRepository", "DeleteGrant", time.Now())
+
+	grant := &entity.PermissionGrant{}
+	err := gr.db.QueryRowContext(ctx, `
+        DELETE FROM permission_grants
+        WHERE id = $1
+        RETURNING id, user_id, permission, feature_id, created_at
+    `, id).Scan(&grant.ID, &grant.UserID, &grant.Permission, &grant.FeatureID, &grant.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// APIKeyRepository хранит API ключи в памяти и повторяет поведение db.APIKeyRepository
+type APIKeyRepository struct {
+	mu     sync.RWMutex
+	keys   map[int]entity.APIKey
+	nextID int
+}
+
+// NewAPIKeyRepository создает новый экземпляр APIKeyRepository
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{keys: make(map[int]entity.APIKey)}
+}
+
+// copyAPIKey возвращает копию ключа, не разделяющую с хранилищем срез разрешений и время
+func copyAPIKey(key entity.APIKey) *entity.APIKey {
+	key.Scopes = append([]string{}, key.Scopes...)
+	if key.ExpiresAt != nil {
+		expiresAt := *key.ExpiresAt
+		key.ExpiresAt = &expiresAt
+	}
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		key.LastUsedAt = &lastUsedAt
+	}
+	return &key
+}
+
+// CreateAPIKey сохраняет новый ключ и заполняет его ID и время создания
+func (kr *APIKeyRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	kr.nextID++
+	key.ID = kr.nextID
+	key.CreatedAt = time.Now()
+	kr.keys[key.ID] = *copyAPIKey(*key)
+
+	return nil
+}
+
+// GetAPIKeyByHash возвращает ключ по хешу его значения
+func (kr *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, key := range kr.keys {
+		if key.KeyHash == keyHash {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// GetAPIKeys возвращает все ключи, упорядоченные по ID
+func (kr *APIKeyRepository) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	keys := make([]*entity.APIKey, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys, nil
+}
+
+// DeleteAPIKey удаляет ключ и возвращает его; для отсутствующего ключа возвращает sql.ErrNoRows
+func (kr *APIKeyRepository) DeleteAPIKey(ctx context.Context, id int) (*entity.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	key, ok := kr.keys[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	delete(kr.keys, id)
+
+	return copyAPIKey(key), nil
+}
+
+// TouchAPIKey запоминает время последнего использования ключа
+func (kr *APIKeyRepository) TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if key, ok := kr.keys[id]; ok {
+		key.LastUsedAt = &usedAt
+		kr.keys[id] = key
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// AuditRepository хранит журнал аудита в памяти и повторяет поведение db.AuditRepository
+type AuditRepository struct {
+	mu      sync.RWMutex
+	records []entity.AuditRecord
+}
+
+// NewAuditRepository создает новый экземпляр AuditRepository
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// CreateAuditRecord добавляет запись в журнал аудита и заполняет ее ID и время
+func (repo *AuditRepository) CreateAuditRecord(ctx context.Context, record *entity.AuditRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record.ID = len(repo.records) + 1
+	record.CreatedAt = time.Now()
+	repo.records = append(repo.records, *record)
+
+	return nil
+}
+
+// GetAuditRecords получает записи журнала аудита по фильтру, начиная с последних
+func (repo *AuditRepository) GetAuditRecords(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var records []*entity.AuditRecord
+	for i := len(repo.records) - 1; i >= 0 && len(records) < filter.Limit; i-- {
+		record := repo.records[i]
+		if filter.Entity != "" && record.Entity != filter.Entity ||
+			filter.ActorID != 0 && record.ActorID != filter.ActorID ||
+			!filter.From.IsZero() && record.CreatedAt.Before(filter.From) ||
+			!filter.To.IsZero() && !record.CreatedAt.Before(filter.To) {
+			continue
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,422 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// BannerRepository хранит баннеры в памяти и повторяет поведение db.BannerRepository
+type BannerRepository struct {
+	mu            sync.RWMutex
+	banners       map[int]*entity.Banner
+	deletedAt     map[int]time.Time
+	nextID        int
+	nextVariantID int
+}
+
+// transactionKey является ключом контекста, под которым InTransaction передает репозиторий, держащий блокировку
+type transactionKey struct{}
+
+// NewBannerRepository создает новый экземпляр BannerRepository
+func NewBannerRepository() *BannerRepository {
+	return &BannerRepository{
+		banners:   make(map[int]*entity.Banner),
+		deletedAt: make(map[int]time.Time),
+	}
+}
+
+// CreateBanner сохраняет новый баннер и заполняет ID баннера и его вариантов
+func (repo *BannerRepository) CreateBanner(ctx context.Context, banner *entity.Banner) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer repo.lock(ctx)()
+
+	repo.nextID++
+	banner.ID = repo.nextID
+	banner.CreatedAt = time.Now()
+	banner.UpdatedAt = banner.CreatedAt
+	banner.Version = 1
+	repo.assignVariantIDs(banner)
+
+	repo.banners[banner.ID] = cloneBanner(banner)
+
+	return nil
+}
+
+// GetBannerByID получает баннер по его ID; с useLastRevision учитываются только баннеры, созданные за последние 5 минут
+func (repo *BannerRepository) GetBannerByID(ctx context.Context, id int, useLastRevision bool) (*entity.Banner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer repo.rlock(ctx)()
+
+	banner, ok := repo.live(id)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if useLastRevision && banner.CreatedAt.Before(time.Now().Add(-5*time.Minute)) {
+		return nil, sql.ErrNoRows
+	}
+
+	return cloneBanner(banner), nil
+}
+
+// GetUserBanner получает баннер по тегу и фиче, окно показа которого включает момент now
+func (repo *BannerRepository) GetUserBanner(ctx context.Context, tagID, featureID int, now time.Time) (*entity.Banner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer repo.rlock(ctx)()
+
+	var found *entity.Banner
+	for id, banner := range repo.banners {
+		if _, deleted := repo.deletedAt[id]; deleted {
+			continue
+		}
+		if banner.FeatureID != featureID || !containsTag(banner.TagIDs, tagID) || !banner.IsLive(now) {
+			continue
+		}
+		// Как и в SQL: сначала включенные баннеры, затем более новые
+		if found == nil || banner.IsActive && !found.IsActive ||
+			banner.IsActive == found.IsActive && banner.ID > found.ID {
+			found = banner
+		}
+	}
+	if found == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return cloneBanner(found), nil
+}
+
+// GetBannerByFeatureAndTags получает неудаленный баннер фичи, набор тегов которого совпадает с tagIDs;
+// из нескольких таких баннеров выбирается баннер с меньшим ID
+func (repo *BannerRepository) GetBannerByFeatureAndTags(ctx context.Context, featureID int, tagIDs []int) (*entity.Banner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	banners := repo.filter(ctx, func(banner *entity.Banner) bool {
+		return banner.FeatureID == featureID && sameTags(banner.TagIDs, tagIDs)
+	})
+	if len(banners) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return banners[0], nil
+}
+
+// GetScheduledBanners получает баннеры, окно показа которых начинается или заканчивается в интервале (from, to]
+func (repo *BannerRepository) GetScheduledBanners(ctx context.Context, from, to time.Time) ([]*entity.Banner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	inRange := func(t *time.Time) bool {
+		return t != nil && t.After(from) && !t.After(to)
+	}
+
+	return repo.filter(ctx, func(banner *entity.Banner) bool {
+		return inRange(banner.ActiveFrom) || inRange(banner.ActiveUntil)
+	}), nil
+}
+
+// GetSnapshotBanners получает все неудаленные баннеры, упорядоченные по ID
+func (repo *BannerRepository) GetSnapshotBanners(ctx context.Context) ([]*entity.Banner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return repo.filter(ctx, func(*entity.Banner) bool { return true }), nil
+}
+
+// UpdateBanner заменяет сохраненный баннер и увеличивает его версию; отсутствующий баннер, как и в SQL, не считается ошибкой.
+// Если banner.Version не равна нулю и отличается от сохраненной, возвращает entity.ErrVersionConflict
+func (repo *BannerRepository) UpdateBanner(ctx context.Context, banner *entity.Banner) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer repo.lock(ctx)()
+
+	existing, ok := repo.live(banner.ID)
+	if !ok {
+		return nil
+	}
+	if banner.Version != 0 && banner.Version != existing.Version {
+		return entity.ErrVersionConflict
+	}
+
+	banner.CreatedAt = existing.CreatedAt
+	banner.UpdatedAt = time.Now()
+	banner.Version = existing.Version + 1
+	repo.assignVariantIDs(banner)
+	repo.banners[banner.ID] = cloneBanner(banner)
+
+	return nil
+}
+
+// DeleteBannerByID помечает баннер удаленным
+func (repo *BannerRepository) DeleteBannerByID(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer repo.lock(ctx)()
+
+	if _, ok := repo.live(id); ok {
+		repo.deletedAt[id] = time.Now()
+	}
+
+	return nil
+}
+
+// RestoreBanner снимает с баннера пометку об удалении; если удаленного баннера нет, возвращает sql.ErrNoRows
+func (repo *BannerRepository) RestoreBanner(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer repo.lock(ctx)()
+
+	if _, deleted := repo.deletedAt[id]; !deleted {
+		return sql.ErrNoRows
+	}
+	delete(repo.deletedAt, id)
+	repo.banners[id].UpdatedAt = time.Now()
+
+	return nil
+}
+
+// PurgeDeletedBanners окончательно удаляет баннеры, помеченные удаленными раньше момента before
+func (repo *BannerRepository) PurgeDeletedBanners(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	defer repo.lock(ctx)()
+
+	purged := 0
+	for id, deletedAt := range repo.deletedAt {
+		if deletedAt.Before(before) {
+			delete(repo.banners, id)
+			delete(repo.deletedAt, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// GetAllBanners получает страницу баннеров с учетом фильтров, сортировки и курсора
+func (repo *BannerRepository) GetAllBanners(ctx context.Context, query entity.BannerListQuery) ([]*entity.Banner, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sortBy := query.Sort
+	if sortBy == "" {
+		sortBy = entity.BannerSortID
+	}
+	if sortBy != entity.BannerSortID && sortBy != entity.BannerSortCreatedAt && sortBy != entity.BannerSortUpdatedAt {
+		return nil, fmt.Errorf("неизвестное поле сортировки %q", query.Sort)
+	}
+
+	// compare упорядочивает баннеры по полю сортировки, а при равенстве - по ID
+	compare := func(id int, at time.Time, other *entity.Banner) int {
+		if sortBy != entity.BannerSortID {
+			if otherAt := other.SortValue(sortBy); !at.Equal(otherAt) {
+				if at.Before(otherAt) {
+					return -1
+				}
+				return 1
+			}
+		}
+		return id - other.ID
+	}
+	if query.Desc {
+		asc := compare
+		compare = func(id int, at time.Time, other *entity.Banner) int {
+			return -asc(id, at, other)
+		}
+	}
+
+	banners := repo.filter(ctx, func(banner *entity.Banner) bool {
+		return matchesFilter(banner, query.BannerFilter) &&
+			(query.After == nil || compare(query.After.ID, query.After.At, banner) < 0)
+	})
+	sort.Slice(banners, func(i, j int) bool {
+		return compare(banners[i].ID, banners[i].SortValue(sortBy), banners[j]) < 0
+	})
+
+	if query.Offset >= len(banners) {
+		return nil, nil
+	}
+	banners = banners[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(banners) {
+		banners = banners[:query.Limit]
+	}
+
+	return banners, nil
+}
+
+// CountBanners считает баннеры, удовлетворяющие фильтрам
+func (repo *BannerRepository) CountBanners(ctx context.Context, filter entity.BannerFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	banners := repo.filter(ctx, func(banner *entity.Banner) bool {
+		return matchesFilter(banner, filter)
+	})
+
+	return len(banners), nil
+}
+
+// filter возвращает копии неудаленных баннеров, удовлетворяющих условию, упорядоченные по ID
+func (repo *BannerRepository) filter(ctx context.Context, match func(banner *entity.Banner) bool) []*entity.Banner {
+	defer repo.rlock(ctx)()
+
+	var banners []*entity.Banner
+	for id, banner := range repo.banners {
+		if _, deleted := repo.deletedAt[id]; !deleted && match(banner) {
+			banners = append(banners, cloneBanner(banner))
+		}
+	}
+
+	sort.Slice(banners, func(i, j int) bool {
+		return banners[i].ID < banners[j].ID
+	})
+
+	return banners
+}
+
+// InTransaction выполняет fn под блокировкой хранилища и при ошибке возвращает хранилище к состоянию до вызова.
+// Вызовы репозитория с контекстом fn не блокируют хранилище повторно, а остальные запросы ждут завершения fn,
+// поэтому не видят незафиксированных изменений, а откат не затирает их изменения
+func (repo *BannerRepository) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if repo.inTransaction(ctx) {
+		// Вызов уже выполняется внутри транзакции
+		return fn(ctx)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	banners := make(map[int]*entity.Banner, len(repo.banners))
+	for id, banner := range repo.banners {
+		banners[id] = cloneBanner(banner)
+	}
+	deletedAt := make(map[int]time.Time, len(repo.deletedAt))
+	for id, at := range repo.deletedAt {
+		deletedAt[id] = at
+	}
+
+	// Как и последовательности PostgreSQL, счетчики ID не откатываются, чтобы ID не выдавались повторно
+	if err := fn(context.WithValue(ctx, transactionKey{}, repo)); err != nil {
+		repo.banners, repo.deletedAt = banners, deletedAt
+		return err
+	}
+
+	return nil
+}
+
+// inTransaction сообщает, выполняется ли вызов внутри InTransaction этого репозитория
+func (repo *BannerRepository) inTransaction(ctx context.Context) bool {
+	return ctx.Value(transactionKey{}) == repo
+}
+
+// lock блокирует хранилище на запись и возвращает функцию снятия блокировки; внутри транзакции блокировку уже держит InTransaction
+func (repo *BannerRepository) lock(ctx context.Context) func() {
+	if repo.inTransaction(ctx) {
+		return func() {}
+	}
+	repo.mu.Lock()
+	return repo.mu.Unlock
+}
+
+// rlock блокирует хранилище на чтение и возвращает функцию снятия блокировки
+func (repo *BannerRepository) rlock(ctx context.Context) func() {
+	if repo.inTransaction(ctx) {
+		return func() {}
+	}
+	repo.mu.RLock()
+	return repo.mu.RUnlock
+}
+
+// live возвращает баннер, если он есть и не помечен удаленным; вызывается под блокировкой
+func (repo *BannerRepository) live(id int) (*entity.Banner, bool) {
+	banner, ok := repo.banners[id]
+	if !ok {
+		return nil, false
+	}
+	if _, deleted := repo.deletedAt[id]; deleted {
+		return nil, false
+	}
+	return banner, true
+}
+
+// assignVariantIDs выдает ID вариантам содержимого; вызывается под блокировкой
+func (repo *BannerRepository) assignVariantIDs(banner *entity.Banner) {
+	for i := range banner.Variants {
+		repo.nextVariantID++
+		banner.Variants[i].ID = repo.nextVariantID
+	}
+}
+
+// cloneBanner копирует баннер вместе со срезами и временными метками
+func cloneBanner(banner *entity.Banner) *entity.Banner {
+	clone := *banner
+	clone.TagIDs = append([]int(nil), banner.TagIDs...)
+	clone.Variants = append([]entity.BannerVariant(nil), banner.Variants...)
+	if banner.ActiveFrom != nil {
+		activeFrom := *banner.ActiveFrom
+		clone.ActiveFrom = &activeFrom
+	}
+	if banner.ActiveUntil != nil {
+		activeUntil := *banner.ActiveUntil
+		clone.ActiveUntil = &activeUntil
+	}
+	return &clone
+}
+
+// containsTag проверяет, связан ли тег с баннером
+func containsTag(tagIDs []int, tagID int) bool {
+	for _, id := range tagIDs {
+		if id == tagID {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTags сравнивает наборы тегов без учета порядка
+func sameTags(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]int(nil), a...)
+	b = append([]int(nil), b...)
+	sort.Ints(a)
+	sort.Ints(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
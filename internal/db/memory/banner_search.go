@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"Avito_task/internal/entity"
+)
+
+// matchesFilter проверяет баннер на соответствие фильтрам списка так же, как условие WHERE в db.BannerRepository
+func matchesFilter(banner *entity.Banner, filter entity.BannerFilter) bool {
+	if filter.TagID != 0 && !containsTag(banner.TagIDs, filter.TagID) {
+		return false
+	}
+	if filter.FeatureID != 0 && banner.FeatureID != filter.FeatureID {
+		return false
+	}
+	if filter.Search == "" && len(filter.Content) == 0 {
+		return true
+	}
+
+	var content interface{}
+	if err := json.Unmarshal([]byte(banner.JSONStructure), &content); err != nil {
+		return false
+	}
+
+	if filter.Search != "" && !containsWords(content, filter.Search) {
+		return false
+	}
+
+	for _, condition := range filter.Content {
+		value, ok := contentValue(content, condition.Path)
+		if !ok {
+			return false
+		}
+		switch condition.Op {
+		case entity.ContentFilterContains:
+			if !strings.Contains(strings.ToLower(value), strings.ToLower(condition.Value)) {
+				return false
+			}
+		default:
+			if value != condition.Value {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// containsWords проверяет, что все слова запроса встречаются в строковых значениях содержимого.
+// Это приближение jsonb_to_tsvector('simple', ...) @@ plainto_tsquery('simple', ...)
+func containsWords(content interface{}, search string) bool {
+	words := make(map[string]bool)
+	collectWords(content, words)
+
+	for _, word := range splitWords(search) {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// collectWords собирает слова из всех строковых значений содержимого
+func collectWords(value interface{}, words map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		for _, word := range splitWords(v) {
+			words[word] = true
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			collectWords(item, words)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectWords(item, words)
+		}
+	}
+}
+
+// splitWords разбивает текст на слова в нижнем регистре
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// contentValue возвращает значение по пути в виде текста, как оператор #>> в PostgreSQL
+func contentValue(content interface{}, path []string) (string, bool) {
+	value := content
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			item, ok := v[key]
+			if !ok {
+				return "", false
+			}
+			value = item
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			value = v[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"Avito_task/internal/entity"
+)
+
+// FeatureRepository хранит фичи в памяти и повторяет поведение db.FeatureRepository
+type FeatureRepository struct {
+	mu       sync.RWMutex
+	features map[int]entity.Feature
+	nextID   int
+}
+
+// NewFeatureRepository создает новый экземпляр FeatureRepository
+func NewFeatureRepository() *FeatureRepository {
+	return &FeatureRepository{features: make(map[int]entity.Feature)}
+}
+
+// CreateFeature сохраняет новую фичу и заполняет ее ID
+func (repo *FeatureRepository) CreateFeature(ctx context.Context, feature *entity.Feature) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.nextID++
+	feature.ID = repo.nextID
+	repo.features[feature.ID] = *feature
+
+	return nil
+}
+
+// GetFeatureByID получает фичу по ее ID
+func (repo *FeatureRepository) GetFeatureByID(ctx context.Context, id int) (*entity.Feature, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	feature, ok := repo.features[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &feature, nil
+}
+
+// UpdateFeature обновляет название фичи; отсутствующая фича, как и в SQL, не считается ошибкой
+func (repo *FeatureRepository) UpdateFeature(ctx context.Context, id int, newName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if feature, ok := repo.features[id]; ok {
+		feature.Name = newName
+		repo.features[id] = feature
+	}
+
+	return nil
+}
+
+// DeleteFeatureByID удаляет фичу по ее ID
+func (repo *FeatureRepository) DeleteFeatureByID(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.features, id)
+
+	return nil
+}
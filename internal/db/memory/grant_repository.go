@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// GrantRepository хранит разрешения пользователей на фичи в памяти и повторяет поведение db.GrantRepository
+type GrantRepository struct {
+	mu     sync.RWMutex
+	grants map[int]entity.PermissionGrant
+	nextID int
+}
+
+// NewGrantRepository создает новый экземпляр GrantRepository
+func NewGrantRepository() *GrantRepository {
+	return &GrantRepository{grants: make(map[int]entity.PermissionGrant)}
+}
+
+// CreateGrant выдает разрешение и заполняет его ID и время; повторная выдача возвращает существующую запись
+func (gr *GrantRepository) CreateGrant(ctx context.Context, grant *entity.PermissionGrant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	for _, existing := range gr.grants {
+		if existing.UserID == grant.UserID && existing.Permission == grant.Permission && existing.FeatureID == grant.FeatureID {
+			*grant = existing
+			return nil
+		}
+	}
+
+	gr.nextID++
+	grant.ID = gr.nextID
+	grant.CreatedAt = time.Now()
+	gr.grants[grant.ID] = *grant
+
+	return nil
+}
+
+// GetGrants возвращает разрешения пользователя userID или, при userID == 0, всех пользователей
+func (gr *GrantRepository) GetGrants(ctx context.Context, userID int) ([]*entity.PermissionGrant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+
+	grants := []*entity.PermissionGrant{}
+	for _, grant := range gr.grants {
+		if userID == 0 || grant.UserID == userID {
+			grant := grant
+			grants = append(grants, &grant)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ID < grants[j].ID })
+
+	return grants, nil
+}
+
+// DeleteGrant отзывает разрешение и возвращает его; для отсутствующего разрешения возвращает sql.ErrNoRows
+func (gr *GrantRepository) DeleteGrant(ctx context.Context, id int) (*entity.PermissionGrant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	grant, ok := gr.grants[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	delete(gr.grants, id)
+
+	return &grant, nil
+}
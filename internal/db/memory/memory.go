@@ -0,0 +1,32 @@
+// Package memory содержит потокобезопасные реализации репозиториев в памяти.
+// Они повторяют поведение SQL репозиториев из пакета db, включая sql.ErrNoRows
+// для отсутствующих записей, и позволяют запускать HTTP стек без PostgreSQL.
+package memory
+
+import (
+	"Avito_task/internal/db"
+	"Avito_task/internal/usecase"
+)
+
+// Проверка того, что SQL и in-memory репозитории реализуют одни и те же интерфейсы
+var (
+	_ usecase.BannerRepository  = (*db.BannerRepository)(nil)
+	_ usecase.TagRepository     = (*db.TagRepository)(nil)
+	_ usecase.FeatureRepository = (*db.FeatureRepository)(nil)
+	_ usecase.UserRepository    = (*db.UserRepository)(nil)
+	_ usecase.RoleRepository    = (*db.RoleRepository)(nil)
+	_ usecase.GrantRepository   = (*db.GrantRepository)(nil)
+	_ usecase.APIKeyRepository  = (*db.APIKeyRepository)(nil)
+	_ usecase.StatsRepository   = (*db.StatsRepository)(nil)
+	_ usecase.AuditRepository   = (*db.AuditRepository)(nil)
+
+	_ usecase.BannerRepository  = (*BannerRepository)(nil)
+	_ usecase.TagRepository     = (*TagRepository)(nil)
+	_ usecase.FeatureRepository = (*FeatureRepository)(nil)
+	_ usecase.UserRepository    = (*UserRepository)(nil)
+	_ usecase.RoleRepository    = (*RoleRepository)(nil)
+	_ usecase.GrantRepository   = (*GrantRepository)(nil)
+	_ usecase.APIKeyRepository  = (*APIKeyRepository)(nil)
+	_ usecase.StatsRepository   = (*StatsRepository)(nil)
+	_ usecase.AuditRepository   = (*AuditRepository)(nil)
+)
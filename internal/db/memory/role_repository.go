@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+
+	"Avito_task/internal/entity"
+)
+
+// RoleRepository хранит роли в памяти и повторяет поведение db.RoleRepository
+type RoleRepository struct {
+	mu    sync.RWMutex
+	roles map[string]entity.Role
+}
+
+// NewRoleRepository создает новый экземпляр RoleRepository со встроенными ролями
+func NewRoleRepository() *RoleRepository {
+	repo := &RoleRepository{roles: make(map[string]entity.Role)}
+	for _, role := range entity.BuiltinRoles {
+		repo.roles[role.Name] = copyRole(role)
+	}
+	return repo
+}
+
+// GetRole возвращает роль по имени
+func (rr *RoleRepository) GetRole(ctx context.Context, name string) (*entity.Role, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	role, ok := rr.roles[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	role = copyRole(role)
+	return &role, nil
+}
+
+// GetRoles возвращает все роли, упорядоченные по имени
+func (rr *RoleRepository) GetRoles(ctx context.Context) ([]*entity.Role, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	roles := make([]*entity.Role, 0, len(rr.roles))
+	for _, role := range rr.roles {
+		role = copyRole(role)
+		roles = append(roles, &role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	return roles, nil
+}
+
+// SaveRole создает роль или заменяет ее разрешения
+func (rr *RoleRepository) SaveRole(ctx context.Context, role *entity.Role) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.roles[role.Name] = copyRole(*role)
+
+	return nil
+}
+
+// copyRole копирует роль вместе со списком разрешений, чтобы вызывающий код не менял хранилище
+func copyRole(role entity.Role) entity.Role {
+	role.Permissions = append([]string{}, role.Permissions...)
+	return role
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// statsKey идентифицирует строку дневной статистики
+type statsKey struct {
+	day      time.Time
+	bannerID int
+	tagID    int
+}
+
+// StatsRepository хранит статистику показов и кликов в памяти и повторяет поведение db.StatsRepository
+type StatsRepository struct {
+	mu    sync.RWMutex
+	stats map[statsKey]entity.BannerStat
+}
+
+// NewStatsRepository создает новый экземпляр StatsRepository
+func NewStatsRepository() *StatsRepository {
+	return &StatsRepository{stats: make(map[statsKey]entity.BannerStat)}
+}
+
+// IncrementBannerStats прибавляет счетчики к дневной статистике
+func (repo *StatsRepository) IncrementBannerStats(ctx context.Context, stats []*entity.BannerStat) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, stat := range stats {
+		key := statsKey{day: stat.Date, bannerID: stat.BannerID, tagID: stat.TagID}
+		existing := repo.stats[key]
+		existing.Date = stat.Date
+		existing.BannerID = stat.BannerID
+		existing.TagID = stat.TagID
+		existing.FeatureID = stat.FeatureID
+		existing.Impressions += stat.Impressions
+		existing.Clicks += stat.Clicks
+		repo.stats[key] = existing
+	}
+
+	return nil
+}
+
+// GetBannerStats получает дневную статистику баннера за период [from, to]
+func (repo *StatsRepository) GetBannerStats(ctx context.Context, bannerID int, from, to time.Time) ([]*entity.BannerStat, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var stats []*entity.BannerStat
+	for _, stat := range repo.stats {
+		if stat.BannerID != bannerID || stat.Date.Before(from) || stat.Date.After(to) {
+			continue
+		}
+		stat := stat
+		stat.CalculateCTR()
+		stats = append(stats, &stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].Date.Equal(stats[j].Date) {
+			return stats[i].Date.Before(stats[j].Date)
+		}
+		return stats[i].TagID < stats[j].TagID
+	})
+
+	return stats, nil
+}
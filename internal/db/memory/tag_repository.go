@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"Avito_task/internal/entity"
+)
+
+// TagRepository хранит теги в памяти и повторяет поведение db.TagRepository
+type TagRepository struct {
+	mu     sync.RWMutex
+	tags   map[int]entity.Tag
+	nextID int
+}
+
+// NewTagRepository создает новый экземпляр TagRepository
+func NewTagRepository() *TagRepository {
+	return &TagRepository{tags: make(map[int]entity.Tag)}
+}
+
+// CreateTag сохраняет новый тег и заполняет его ID
+func (repo *TagRepository) CreateTag(ctx context.Context, tag *entity.Tag) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.nextID++
+	tag.ID = repo.nextID
+	repo.tags[tag.ID] = *tag
+
+	return nil
+}
+
+// GetTagByID получает тег по его ID
+func (repo *TagRepository) GetTagByID(ctx context.Context, id int) (*entity.Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	tag, ok := repo.tags[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &tag, nil
+}
+
+// UpdateTag обновляет название тега; отсутствующий тег, как и в SQL, не считается ошибкой
+func (repo *TagRepository) UpdateTag(ctx context.Context, id int, newName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if tag, ok := repo.tags[id]; ok {
+		tag.Name = newName
+		repo.tags[id] = tag
+	}
+
+	return nil
+}
+
+// DeleteTagByID удаляет тег по его ID
+func (repo *TagRepository) DeleteTagByID(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.tags, id)
+
+	return nil
+}
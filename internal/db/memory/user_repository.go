@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// UserRepository хранит пользователей в памяти и повторяет поведение db.UserRepository
+type UserRepository struct {
+	mu     sync.RWMutex
+	users  map[int]entity.User
+	nextID int
+}
+
+// NewUserRepository создает новый экземпляр UserRepository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[int]entity.User)}
+}
+
+// CreateUser сохраняет нового пользователя и заполняет его ID; занятое имя возвращает entity.ErrUsernameTaken
+func (ur *UserRepository) CreateUser(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if ur.usernameTaken(user.Username, 0) {
+		return entity.ErrUsernameTaken
+	}
+
+	ur.nextID++
+	user.ID = ur.nextID
+	ur.users[user.ID] = *user
+
+	return nil
+}
+
+// GetUserByID возвращает пользователя по его ID
+func (ur *UserRepository) GetUserByID(ctx context.Context, id int) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	user, ok := ur.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &user, nil
+}
+
+// GetUserByUsername возвращает пользователя по его имени пользователя
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	for _, user := range ur.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// UpdateUser обновляет пользователя; отсутствующий пользователь, как и в SQL, не считается ошибкой,
+// а имя, занятое другим пользователем, возвращает entity.ErrUsernameTaken
+func (ur *UserRepository) UpdateUser(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if _, ok := ur.users[user.ID]; ok {
+		if ur.usernameTaken(user.Username, user.ID) {
+			return entity.ErrUsernameTaken
+		}
+		ur.users[user.ID] = *user
+	}
+
+	return nil
+}
+
+// usernameTaken проверяет, занято ли имя пользователем, отличным от exceptID; вызывается под блокировкой
+func (ur *UserRepository) usernameTaken(username string, exceptID int) bool {
+	for id, user := range ur.users {
+		if id != exceptID && user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteUserByID удаляет пользователя по его ID
+func (ur *UserRepository) DeleteUserByID(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	delete(ur.users, id)
+
+	return nil
+}
+
+// IncrementFailedLogins увеличивает счетчик неудачных попыток входа подряд и возвращает его новое значение
+func (ur *UserRepository) IncrementFailedLogins(ctx context.Context, id int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	user, ok := ur.users[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	user.FailedLogins++
+	ur.users[id] = user
+
+	return user.FailedLogins, nil
+}
+
+// LockUser запрещает вход пользователя до момента until
+func (ur *UserRepository) LockUser(ctx context.Context, id int, until time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if user, ok := ur.users[id]; ok {
+		user.LockedUntil = &until
+		ur.users[id] = user
+	}
+
+	return nil
+}
+
+// ResetFailedLogins сбрасывает счетчик неудачных попыток входа и блокировку после успешного входа
+func (ur *UserRepository) ResetFailedLogins(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if user, ok := ur.users[id]; ok {
+		user.FailedLogins = 0
+		user.LockedUntil = nil
+		ur.users[id] = user
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"Avito_task/internal/logging"
+	"Avito_task/internal/metrics"
+)
+
+// SlowQueryThreshold задает время, после которого запрос к базе данных считается медленным
+var SlowQueryThreshold = 200 * time.Millisecond
+
+// observeQuery записывает время выполнения метода репозитория и предупреждает о медленных запросах
+func observeQuery(ctx context.Context, repository, method string, start time.Time) {
+	metrics.ObserveQuery(repository, method, start)
+
+	if elapsed := time.Since(start); SlowQueryThreshold > 0 && elapsed > SlowQueryThreshold {
+		logging.FromContext(ctx).Warn("медленный запрос к базе данных",
+			"repository", repository,
+			"method", method,
+			"duration_ms", elapsed.Milliseconds(),
+			"threshold_ms", SlowQueryThreshold.Milliseconds(),
+		)
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"Avito_task/internal/entity"
+)
+
+// RoleRepository представляет репозиторий ролей и их разрешений в базе данных
+type RoleRepository struct {
+	db *sql.DB
+}
+
+// NewRoleRepository создает новый экземпляр RoleRepository
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db}
+}
+
+// GetRole возвращает роль по имени
+func (rr *RoleRepository) GetRole(ctx context.Context, name string) (*entity.Role, error) {
+	defer observeQuery(ctx, "RoleRepository", "GetRole", time.Now())
+
+	role := &entity.Role{}
+	err := rr.db.QueryRowContext(ctx, `
+        SELECT name, permissions
+        FROM roles
+        WHERE name = $1
+    `, name).Scan(&role.Name, pq.Array(&role.Permissions))
+	if err != nil {
+		return nil, err
+	}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	return role, nil
+}
+
+// GetRoles возвращает все роли, упорядоченные по имени
+func (rr *RoleRepository) GetRoles(ctx context.Context) ([]*entity.Role, error) {
+	defer observeQuery(ctx, "RoleRepository", "GetRoles", time.Now())
+
+	rows, err := rr.db.QueryContext(ctx, `
+        SELECT name, permissions
+        FROM roles
+        ORDER BY name
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*entity.Role{}
+	for rows.Next() {
+		role := &entity.Role{}
+		if err := rows.Scan(&role.Name, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		if role.Permissions == nil {
+			role.Permissions = []string{}
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SaveRole создает роль или заменяет ее разрешения
+func (rr *RoleRepository) SaveRole(ctx context.Context, role *entity.Role) error {
+	defer observeQuery(ctx, "RoleRepository", "SaveRole", time.Now())
+
+	_, err := rr.db.ExecContext(ctx, `
+        INSERT INTO roles (name, permissions)
+        VALUES ($1, $2)
+        ON CONFLICT (name) DO UPDATE SET permissions = EXCLUDED.permissions
+    `, role.Name, pq.Array(role.Permissions))
+	return err
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// StatsRepository представляет репозиторий для работы со статистикой показов и кликов
+type StatsRepository struct {
+	DB *sql.DB
+}
+
+// NewStatsRepository создает новый экземпляр StatsRepository
+func NewStatsRepository(db *sql.DB) *StatsRepository {
+	return &StatsRepository{DB: db}
+}
+
+// IncrementBannerStats прибавляет накопленные счетчики к дневной статистике одной транзакцией
+func (repo *StatsRepository) IncrementBannerStats(ctx context.Context, stats []*entity.BannerStat) error {
+	defer observeQuery(ctx, "StatsRepository", "IncrementBannerStats", time.Now())
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO banner_stats (day, banner_id, tag_id, feature_id, impressions, clicks)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (day, banner_id, tag_id) DO UPDATE
+        SET feature_id = EXCLUDED.feature_id,
+            impressions = banner_stats.impressions + EXCLUDED.impressions,
+            clicks = banner_stats.clicks + EXCLUDED.clicks
+    `)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, stat := range stats {
+		_, err := stmt.ExecContext(ctx, stat.Date, stat.BannerID, stat.TagID, stat.FeatureID, stat.Impressions, stat.Clicks)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBannerStats получает дневную статистику баннера за период [from, to]
+func (repo *StatsRepository) GetBannerStats(ctx context.Context, bannerID int, from, to time.Time) ([]*entity.BannerStat, error) {
+	defer observeQuery(ctx, "StatsRepository", "GetBannerStats", time.Now())
+
+	rows, err := repo.DB.QueryContext(ctx, `
+        SELECT day, banner_id, tag_id, feature_id, impressions, clicks
+        FROM banner_stats
+        WHERE banner_id = $1
+        AND day BETWEEN $2 AND $3
+        ORDER BY day, tag_id
+    `, bannerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*entity.BannerStat
+	for rows.Next() {
+		stat := &entity.BannerStat{}
+		if err := rows.Scan(&stat.Date, &stat.BannerID, &stat.TagID, &stat.FeatureID, &stat.Impressions, &stat.Clicks); err != nil {
+			return nil, err
+		}
+		stat.CalculateCTR()
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"Avito_task/internal/entity"
 )
@@ -17,8 +19,10 @@ func NewTagRepository(db *sql.DB) *TagRepository {
 }
 
 // CreateTag создает новый тег в базе данных
-func (repo *TagRepository) CreateTag(tag *entity.Tag) error {
-	_, err := repo.DB.Exec(`
+func (repo *TagRepository) CreateTag(ctx context.Context, tag *entity.Tag) error {
+	defer observeQuery(ctx, "TagRepository", "CreateTag", time.Now())
+
+	_, err := repo.DB.ExecContext(ctx, `
         INSERT INTO tags (name)
         VALUES ($1)
     `, tag.Name)
@@ -27,7 +31,7 @@ func (repo *TagRepository) CreateTag(tag *entity.Tag) error {
 	}
 
 	// Получение ID созданного тега
-	err = repo.DB.QueryRow("SELECT lastval()").Scan(&tag.ID)
+	err = repo.DB.QueryRowContext(ctx, "SELECT lastval()").Scan(&tag.ID)
 	if err != nil {
 		return err
 	}
@@ -36,9 +40,11 @@ func (repo *TagRepository) CreateTag(tag *entity.Tag) error {
 }
 
 // GetTagByID получает тег из базы данных по его ID
-func (repo *TagRepository) GetTagByID(id int) (*entity.Tag, error) {
+func (repo *TagRepository) GetTagByID(ctx context.Context, id int) (*entity.Tag, error) {
+	defer observeQuery(ctx, "TagRepository", "GetTagByID", time.Now())
+
 	tag := &entity.Tag{}
-	err := repo.DB.QueryRow(`
+	err := repo.DB.QueryRowContext(ctx, `
 		SELECT id, name
 		FROM tags
 		WHERE id = $1	
@@ -51,8 +57,10 @@ func (repo *TagRepository) GetTagByID(id int) (*entity.Tag, error) {
 }
 
 // UpdateTag обновляет информацию о теге в базе данных
-func (repo *TagRepository) UpdateTag(id int, newName string) error {
-	_, err := repo.DB.Exec(`
+func (repo *TagRepository) UpdateTag(ctx context.Context, id int, newName string) error {
+	defer observeQuery(ctx, "TagRepository", "UpdateTag", time.Now())
+
+	_, err := repo.DB.ExecContext(ctx, `
 		UPDATE tags
 		SET name = $1
 		WHERE id = $2
@@ -65,8 +73,10 @@ func (repo *TagRepository) UpdateTag(id int, newName string) error {
 }
 
 // DeleteTagByID удаляет тег из базы данных по его ID
-func (repo *TagRepository) DeleteTagByID(id int) error {
-	_, err := repo.DB.Exec(`
+func (repo *TagRepository) DeleteTagByID(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "TagRepository", "DeleteTagByID", time.Now())
+
+	_, err := repo.DB.ExecContext(ctx, `
 		DELETE FROM tags
 		WHERE id = $1
 	`, id)
@@ -2,9 +2,17 @@ package db
 
 import (
 	"Avito_task/internal/entity"
+	"context"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// uniqueViolation является кодом ошибки PostgreSQL при нарушении уникального индекса
+const uniqueViolation = "23505"
+
 // UserRepository представляет репозиторий для работы с сущностью пользователя в базе данных.
 type UserRepository struct {
 	db *sql.DB
@@ -15,26 +23,31 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db}
 }
 
-// CreateUser создает нового пользователя в базе данных.
-func (ur *UserRepository) CreateUser(user *entity.User) error {
-	_, err := ur.db.Exec(`
-        INSERT INTO users (username, password, token, is_admin)
-        VALUES ($1, $2, $3, $4)
-    `, user.Username, user.PasswordHash, user.Token, user.IsAdmin)
+// CreateUser создает нового пользователя в базе данных; занятое имя возвращает entity.ErrUsernameTaken.
+func (ur *UserRepository) CreateUser(ctx context.Context, user *entity.User) error {
+	defer observeQuery(ctx, "UserRepository", "CreateUser", time.Now())
+
+	err := ur.db.QueryRowContext(ctx, `
+        INSERT INTO users (username, password_hash, role)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `, user.Username, user.PasswordHash, user.Role).Scan(&user.ID)
 	if err != nil {
-		return err
+		return usernameError(err)
 	}
 	return nil
 }
 
 // GetUserByID возвращает пользователя из базы данных по его ID.
-func (ur *UserRepository) GetUserByID(id int) (*entity.User, error) {
+func (ur *UserRepository) GetUserByID(ctx context.Context, id int) (*entity.User, error) {
+	defer observeQuery(ctx, "UserRepository", "GetUserByID", time.Now())
+
 	user := &entity.User{}
-	err := ur.db.QueryRow(`
-        SELECT id, username, password, token, is_admin
+	err := ur.db.QueryRowContext(ctx, `
+        SELECT id, username, password_hash, role, failed_logins, locked_until
         FROM users
         WHERE id = $1
-    `, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Token, &user.IsAdmin)
+    `, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.FailedLogins, &user.LockedUntil)
 	if err != nil {
 		return nil, err
 	}
@@ -42,13 +55,15 @@ func (ur *UserRepository) GetUserByID(id int) (*entity.User, error) {
 }
 
 // GetUserByUsername получает пользователя из базы данных по его имени пользователя (Username)
-func (ur *UserRepository) GetUserByUsername(username string) (*entity.User, error) {
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	defer observeQuery(ctx, "UserRepository", "GetUserByUsername", time.Now())
+
 	user := &entity.User{}
-	err := ur.db.QueryRow(`
-        SELECT id, username, password, token, is_admin
+	err := ur.db.QueryRowContext(ctx, `
+        SELECT id, username, password_hash, role, failed_logins, locked_until
         FROM users
         WHERE username = $1
-    `, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Token, &user.IsAdmin)
+    `, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.FailedLogins, &user.LockedUntil)
 	if err != nil {
 		return nil, err
 	}
@@ -56,22 +71,35 @@ func (ur *UserRepository) GetUserByUsername(username string) (*entity.User, erro
 	return user, nil
 }
 
-// UpdateUser обновляет информацию о пользователе в базе данных.
-func (ur *UserRepository) UpdateUser(user *entity.User) error {
-	_, err := ur.db.Exec(`
+// UpdateUser обновляет информацию о пользователе в базе данных; занятое имя возвращает entity.ErrUsernameTaken.
+func (ur *UserRepository) UpdateUser(ctx context.Context, user *entity.User) error {
+	defer observeQuery(ctx, "UserRepository", "UpdateUser", time.Now())
+
+	_, err := ur.db.ExecContext(ctx, `
         UPDATE users
-        SET username = $1, password = $2, token = $3, is_admin = $4
-        WHERE id = $5
-    `, user.Username, user.PasswordHash, user.Token, user.IsAdmin, user.ID)
+        SET username = $1, password_hash = $2, role = $3
+        WHERE id = $4
+    `, user.Username, user.PasswordHash, user.Role, user.ID)
 	if err != nil {
-		return err
+		return usernameError(err)
 	}
 	return nil
 }
 
+// usernameError заменяет нарушение уникальности имени пользователя на entity.ErrUsernameTaken
+func usernameError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == "users_username_idx" {
+		return entity.ErrUsernameTaken
+	}
+	return err
+}
+
 // DeleteUserByID удаляет пользователя из базы данных по его ID.
-func (ur *UserRepository) DeleteUserByID(id int) error {
-	_, err := ur.db.Exec(`
+func (ur *UserRepository) DeleteUserByID(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "UserRepository", "DeleteUserByID", time.Now())
+
+	_, err := ur.db.ExecContext(ctx, `
         DELETE FROM users
         WHERE id = $1
     `, id)
@@ -80,3 +108,44 @@ func (ur *UserRepository) DeleteUserByID(id int) error {
 	}
 	return nil
 }
+
+// IncrementFailedLogins увеличивает счетчик неудачных попыток входа подряд и возвращает его новое значение
+func (ur *UserRepository) IncrementFailedLogins(ctx context.Context, id int) (int, error) {
+	defer observeQuery(ctx, "UserRepository", "IncrementFailedLogins", time.Now())
+
+	var failures int
+	err := ur.db.QueryRowContext(ctx, `
+        UPDATE users
+        SET failed_logins = failed_logins + 1
+        WHERE id = $1
+        RETURNING failed_logins
+    `, id).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+// LockUser запрещает вход пользователя до момента until
+func (ur *UserRepository) LockUser(ctx context.Context, id int, until time.Time) error {
+	defer observeQuery(ctx, "UserRepository", "LockUser", time.Now())
+
+	_, err := ur.db.ExecContext(ctx, `
+        UPDATE users
+        SET locked_until = $1
+        WHERE id = $2
+    `, until, id)
+	return err
+}
+
+// ResetFailedLogins сбрасывает счетчик неудачных попыток входа и блокировку после успешного входа
+func (ur *UserRepository) ResetFailedLogins(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "UserRepository", "ResetFailedLogins", time.Now())
+
+	_, err := ur.db.ExecContext(ctx, `
+        UPDATE users
+        SET failed_logins = 0, locked_until = NULL
+        WHERE id = $1
+    `, id)
+	return err
+}
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// APIKeyPrefix начинает каждый API ключ, чтобы его можно было отличить от JWT и найти в логах и конфигурациях
+const APIKeyPrefix = "ak_"
+
+// APIKey представляет долгоживущий ключ доступа для сервисов; сам ключ не хранится, только его хеш
+type APIKey struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Prefix содержит начало ключа, по которому его можно узнать в списке
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedBy  int        `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Expired сообщает, истек ли срок действия ключа к моменту now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CreatedAPIKey возвращается при создании ключа: значение ключа показывается только один раз
+type CreatedAPIKey struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Действия, фиксируемые в журнале аудита
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// Сущности, изменения которых фиксируются в журнале аудита
+const (
+	AuditEntityBanner  = "banner"
+	AuditEntityTag     = "tag"
+	AuditEntityFeature = "feature"
+	AuditEntityUser    = "user"
+	AuditEntityRole    = "role"
+	AuditEntityGrant   = "grant"
+	AuditEntityAPIKey  = "api_key"
+)
+
+// AuditChange содержит значение поля до и после изменения
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditRecord представляет запись журнала аудита
+type AuditRecord struct {
+	ID      int `json:"id"`
+	ActorID int `json:"actor_id"`
+	// APIKeyID задан, если действие выполнено по API ключу; ActorID у такой записи - создатель ключа
+	APIKeyID  int                    `json:"api_key_id,omitempty"`
+	Action    string                 `json:"action"`
+	Entity    string                 `json:"entity"`
+	EntityID  int                    `json:"entity_id"`
+	Diff      map[string]AuditChange `json:"diff"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditFilter описывает условия выборки из журнала аудита; нулевые значения не ограничивают выборку
+type AuditFilter struct {
+	Entity  string
+	ActorID int
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+// AuditDiff сравнивает JSON представления объектов и возвращает изменившиеся поля; nil означает отсутствие объекта
+func AuditDiff(before, after interface{}) (map[string]AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]AuditChange)
+	for name, value := range beforeFields {
+		if afterValue, ok := afterFields[name]; !ok || !reflect.DeepEqual(value, afterValue) {
+			diff[name] = AuditChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			diff[name] = AuditChange{After: value}
+		}
+	}
+
+	return diff, nil
+}
+
+// auditFields раскладывает объект на поля по его JSON представлению
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
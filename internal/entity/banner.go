@@ -1,10 +1,82 @@
 package entity
 
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ErrVersionConflict возвращается хранилищем и сценариями использования, если баннер изменился после того,
+// как клиент получил его версию
+var ErrVersionConflict = errors.New("версия баннера устарела")
+
 type Banner struct {
-	ID              int
-	JSONStructure   string
-	FeatureID       int
-	TagIDs          []int
-	IsActive        bool
-	UseLastRevision bool
+	ID              int             `json:"banner_id"`
+	JSONStructure   string          `json:"json_structure"`
+	FeatureID       int             `json:"feature_id"`
+	TagIDs          []int           `json:"tag_ids"`
+	IsActive        bool            `json:"is_active"`
+	UseLastRevision bool            `json:"-"`
+	ActiveFrom      *time.Time      `json:"active_from,omitempty"`
+	ActiveUntil     *time.Time      `json:"active_until,omitempty"`
+	Variants        []BannerVariant `json:"variants,omitempty"`
+	VariantID       int             `json:"variant_id,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	// Version увеличивается при каждом обновлении и передается клиентам в ETag
+	Version int `json:"version"`
+}
+
+// BannerVariant представляет вариант содержимого баннера для A/B тестирования
+type BannerVariant struct {
+	ID            int    `json:"variant_id"`
+	JSONStructure string `json:"json_structure"`
+	Weight        int    `json:"weight"`
+}
+
+// IsLive сообщает, попадает ли момент now в окно показа баннера
+func (b *Banner) IsLive(now time.Time) bool {
+	if b.ActiveFrom != nil && now.Before(*b.ActiveFrom) {
+		return false
+	}
+	if b.ActiveUntil != nil && !now.Before(*b.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+// PickVariant детерминированно выбирает вариант содержимого для пользователя с учетом весов
+func (b *Banner) PickVariant(userID int) *BannerVariant {
+	return b.pickVariant(fmt.Sprintf("%d:%d", b.ID, userID))
+}
+
+// PickAPIKeyVariant детерминированно выбирает вариант содержимого для сервиса с API ключом apiKeyID;
+// ключи распределяются по вариантам отдельно от пользователей, в том числе от создателя ключа
+func (b *Banner) PickAPIKeyVariant(apiKeyID int) *BannerVariant {
+	return b.pickVariant(fmt.Sprintf("%d:api_key:%d", b.ID, apiKeyID))
+}
+
+// pickVariant выбирает вариант по хешу seed пропорционально весам
+func (b *Banner) pickVariant(seed string) *BannerVariant {
+	totalWeight := 0
+	for _, variant := range b.Variants {
+		totalWeight += variant.Weight
+	}
+	if totalWeight <= 0 {
+		return nil
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(seed))
+	point := int(hash.Sum32() % uint32(totalWeight))
+
+	for i := range b.Variants {
+		point -= b.Variants[i].Weight
+		if point < 0 {
+			return &b.Variants[i]
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,23 @@
+package entity
+
+// Типы изменений баннеров в ленте изменений
+const (
+	BannerEventCreate  = "create"
+	BannerEventUpdate  = "update"
+	BannerEventDelete  = "delete"
+	BannerEventRestore = "restore"
+	BannerEventPurge   = "purge"
+	// BannerEventResync означает, что часть событий могла быть потеряна и подписчику нужно перечитать состояние целиком
+	BannerEventResync = "resync"
+)
+
+// BannerEvent представляет событие ленты изменений баннеров
+type BannerEvent struct {
+	BannerID int    `json:"banner_id"`
+	Change   string `json:"change"`
+	// FeatureID и TagIDs задают пары (тег, фича) баннера после изменения, если они известны
+	FeatureID int   `json:"feature_id,omitempty"`
+	TagIDs    []int `json:"tag_ids,omitempty"`
+	// Origin идентифицирует реплику, изменившую баннер; пуст у событий, созданных самой лентой
+	Origin string `json:"origin,omitempty"`
+}
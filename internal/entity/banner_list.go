@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Поля сортировки списка баннеров
+const (
+	BannerSortID        = "id"
+	BannerSortCreatedAt = "created_at"
+	BannerSortUpdatedAt = "updated_at"
+)
+
+// BannerCursor указывает на последний баннер предыдущей страницы
+type BannerCursor struct {
+	ID int
+	// At содержит значение поля сортировки, если сортировка идет не по ID
+	At time.Time
+}
+
+// Операторы фильтров по полям содержимого баннера
+const (
+	ContentFilterContains = "contains"
+	ContentFilterEquals   = "="
+)
+
+// ErrInvalidContentFilter возвращается для фильтра, не соответствующего формату "content.<путь> <оператор> <значение>"
+var ErrInvalidContentFilter = errors.New("неверный фильтр по содержимому баннера")
+
+// ContentFilter описывает условие на значение поля содержимого баннера по пути Path
+type ContentFilter struct {
+	Path  []string
+	Op    string
+	Value string
+}
+
+// ParseContentFilter разбирает фильтр вида "content.url contains avito.ru"
+func ParseContentFilter(s string) (ContentFilter, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), " ", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "content.") {
+		return ContentFilter{}, ErrInvalidContentFilter
+	}
+	if parts[1] != ContentFilterContains && parts[1] != ContentFilterEquals {
+		return ContentFilter{}, ErrInvalidContentFilter
+	}
+
+	path := strings.Split(strings.TrimPrefix(parts[0], "content."), ".")
+	for _, key := range path {
+		if key == "" {
+			return ContentFilter{}, ErrInvalidContentFilter
+		}
+	}
+
+	return ContentFilter{Path: path, Op: parts[1], Value: strings.TrimSpace(parts[2])}, nil
+}
+
+// BannerFilter описывает условия отбора баннеров в списке
+type BannerFilter struct {
+	TagID     int
+	FeatureID int
+	// Search содержит слова, которые должны встречаться в строковых полях содержимого
+	Search  string
+	Content []ContentFilter
+}
+
+// BannerListQuery описывает фильтры, сортировку и границы страницы списка баннеров
+type BannerListQuery struct {
+	BannerFilter
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+	After  *BannerCursor
+}
+
+// BannerPage представляет страницу списка баннеров
+type BannerPage struct {
+	Items      []*Banner `json:"items"`
+	Total      int       `json:"total"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// SortValue возвращает значение поля сортировки sort, отличного от ID
+func (b *Banner) SortValue(sort string) time.Time {
+	if sort == BannerSortUpdatedAt {
+		return b.UpdatedAt
+	}
+	return b.CreatedAt
+}
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Форматы импорта и экспорта баннеров
+const (
+	BannerFormatNDJSON = "ndjson"
+	BannerFormatCSV    = "csv"
+)
+
+// BannerRecord представляет баннер в файлах импорта и экспорта
+type BannerRecord struct {
+	ID          int             `json:"banner_id,omitempty"`
+	FeatureID   int             `json:"feature_id"`
+	TagIDs      []int           `json:"tag_ids"`
+	Content     json.RawMessage `json:"content"`
+	IsActive    bool            `json:"is_active"`
+	ActiveFrom  *time.Time      `json:"active_from,omitempty"`
+	ActiveUntil *time.Time      `json:"active_until,omitempty"`
+}
+
+// BannerImportError описывает ошибку в строке файла импорта
+type BannerImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// BannerImportReport содержит итоги импорта баннеров
+type BannerImportReport struct {
+	DryRun  bool                `json:"dry_run"`
+	Created int                 `json:"created"`
+	Updated int                 `json:"updated"`
+	Errors  []BannerImportError `json:"errors"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// FeatureScopedPermissions перечисляет разрешения, которые можно выдать пользователю на отдельную фичу
+var FeatureScopedPermissions = []string{PermissionBannerWrite}
+
+// PermissionGrant представляет разрешение, выданное пользователю на одну фичу в дополнение к разрешениям его роли
+type PermissionGrant struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Permission string    `json:"permission"`
+	FeatureID  int       `json:"feature_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IsFeatureScoped сообщает, можно ли выдать разрешение на отдельную фичу
+func IsFeatureScoped(permission string) bool {
+	for _, scoped := range FeatureScopedPermissions {
+		if permission == scoped {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,53 @@
 package entity
 
+import "time"
+
 type CreateBannerRequest struct {
-	TagIDs    []int                  `json:"tag_ids"`
-	FeatureID int                    `json:"feature_id"`
-	Content   map[string]interface{} `json:"content"`
-	IsActive  bool                   `json:"is_active"`
+	TagIDs      []int                  `json:"tag_ids"`
+	FeatureID   int                    `json:"feature_id"`
+	Content     map[string]interface{} `json:"content"`
+	IsActive    bool                   `json:"is_active"`
+	ActiveFrom  *time.Time             `json:"active_from"`
+	ActiveUntil *time.Time             `json:"active_until"`
+	Variants    []BannerVariantRequest `json:"variants"`
+}
+
+type UpdateBannerRequest struct {
+	TagIDs      []int                  `json:"tag_ids"`
+	FeatureID   int                    `json:"feature_id"`
+	Content     map[string]interface{} `json:"content"`
+	IsActive    bool                   `json:"is_active"`
+	ActiveFrom  *time.Time             `json:"active_from"`
+	ActiveUntil *time.Time             `json:"active_until"`
+	Variants    []BannerVariantRequest `json:"variants"`
+}
+
+type BannerVariantRequest struct {
+	Content map[string]interface{} `json:"content"`
+	Weight  int                    `json:"weight"`
+}
+
+// LoginRequest содержит учетные данные для получения токена
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// SaveRoleRequest содержит разрешения создаваемой или изменяемой роли
+type SaveRoleRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// CreateGrantRequest содержит разрешение, которое выдается пользователю на фичу
+type CreateGrantRequest struct {
+	UserID     int    `json:"user_id"`
+	Permission string `json:"permission"`
+	FeatureID  int    `json:"feature_id"`
+}
+
+// CreateAPIKeyRequest содержит имя, разрешения и срок действия нового API ключа; без expires_at ключ бессрочный
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
 }
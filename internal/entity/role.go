@@ -0,0 +1,70 @@
+package entity
+
+// Разрешения, из которых складываются роли
+const (
+	// PermissionBannerRead дает доступ к списку баннеров, расписанию, статистике, выгрузке и неактивным баннерам
+	PermissionBannerRead = "banner:read"
+	// PermissionBannerWrite дает право создавать, изменять, удалять, восстанавливать и загружать баннеры
+	PermissionBannerWrite = "banner:write"
+	// PermissionCatalogWrite дает право управлять тегами и фичами
+	PermissionCatalogWrite = "catalog:write"
+	// PermissionUserManage дает право управлять пользователями и ролями
+	PermissionUserManage = "user:manage"
+	// PermissionAuditRead дает доступ к журналу аудита
+	PermissionAuditRead = "audit:read"
+)
+
+// RoleEditor является встроенной ролью редактора баннеров без управления пользователями
+const RoleEditor = "editor"
+
+// Permissions перечисляет все известные разрешения
+var Permissions = []string{
+	PermissionBannerRead,
+	PermissionBannerWrite,
+	PermissionCatalogWrite,
+	PermissionUserManage,
+	PermissionAuditRead,
+}
+
+// Role представляет роль пользователя и ее разрешения
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// BuiltinRoles содержит роли, которые создаются вместе с хранилищем
+var BuiltinRoles = []Role{
+	{Name: RoleUser, Permissions: []string{}},
+	{Name: RoleEditor, Permissions: []string{PermissionBannerRead, PermissionBannerWrite}},
+	{Name: RoleAdmin, Permissions: Permissions},
+}
+
+// BuiltinRolePermissions возвращает разрешения встроенной роли или nil, если роль не встроенная
+func BuiltinRolePermissions(name string) []string {
+	for _, role := range BuiltinRoles {
+		if role.Name == name {
+			return role.Permissions
+		}
+	}
+	return nil
+}
+
+// IsPermission сообщает, является ли строка известным разрешением
+func IsPermission(permission string) bool {
+	for _, known := range Permissions {
+		if permission == known {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission сообщает, есть ли у роли разрешение
+func (r *Role) HasPermission(permission string) bool {
+	for _, granted := range r.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// Типы событий расписания баннеров
+const (
+	ScheduleActivation   = "activation"
+	ScheduleDeactivation = "deactivation"
+)
+
+// ScheduleEvent описывает предстоящее включение или выключение баннера
+type ScheduleEvent struct {
+	BannerID  int       `json:"banner_id"`
+	FeatureID int       `json:"feature_id"`
+	TagIDs    []int     `json:"tag_ids"`
+	Type      string    `json:"type"`
+	At        time.Time `json:"at"`
+}
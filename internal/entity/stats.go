@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// Группировки дневной статистики баннера
+const (
+	// StatsGroupByTag разбивает статистику баннера по тегам
+	StatsGroupByTag = "tag"
+	// StatsGroupByFeature разбивает статистику баннера по фичам, суммируя теги
+	StatsGroupByFeature = "feature"
+	// StatsGroupByBanner суммирует статистику баннера по всем тегам и фичам
+	StatsGroupByBanner = "banner"
+)
+
+// BannerStat представляет дневную статистику показов и кликов баннера; TagID и FeatureID равны нулю,
+// если статистика просуммирована по ним
+type BannerStat struct {
+	Date        time.Time `json:"date"`
+	BannerID    int       `json:"banner_id"`
+	TagID       int       `json:"tag_id,omitempty"`
+	FeatureID   int       `json:"feature_id,omitempty"`
+	Impressions int64     `json:"impressions"`
+	Clicks      int64     `json:"clicks"`
+	CTR         float64   `json:"ctr"`
+}
+
+// CalculateCTR вычисляет отношение кликов к показам
+func (s *BannerStat) CalculateCTR() {
+	if s.Impressions == 0 {
+		s.CTR = 0
+		return
+	}
+	s.CTR = float64(s.Clicks) / float64(s.Impressions)
+}
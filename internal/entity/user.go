@@ -1,10 +1,24 @@
 package entity
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrUsernameTaken возвращается хранилищем, если имя пользователя уже занято другим пользователем
+var ErrUsernameTaken = errors.New("имя пользователя уже занято")
+
+// Встроенные роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID           int
-	Username     string
-	PasswordHash string
-	Role         string
-	Token        string
-	IsAdmin      bool
+	ID           int        `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"-"`
+	Role         string     `json:"role"`
+	FailedLogins int        `json:"-"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
 }
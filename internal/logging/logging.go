@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// requestIDKey является ключом идентификатора запроса в context.Context
+type requestIDKey struct{}
+
+// New создает JSON логгер с заданным уровнем и делает его логгером по умолчанию
+func New(w io.Writer, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+
+	logger := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl}))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// WithRequestID возвращает контекст с идентификатором запроса
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext возвращает логгер по умолчанию, дополненный идентификатором запроса из контекста
+func FromContext(ctx context.Context) *slog.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return slog.Default().With("request_id", requestID)
+	}
+	return slog.Default()
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "banner_service"
+
+// Registry содержит все метрики сервиса
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestsTotal считает HTTP запросы по маршруту Gin, методу и статусу
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Количество HTTP запросов.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration измеряет время обработки HTTP запросов
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Время обработки HTTP запросов.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"route", "method", "status"})
+
+	// DBQueryDuration измеряет время выполнения методов репозиториев
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Время выполнения запросов репозиториев.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"repository", "method"})
+
+	// BannerCacheHits считает попадания в кэш баннеров
+	BannerCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "banner_cache_hits_total",
+		Help:      "Количество попаданий в кэш баннеров.",
+	})
+
+	// BannerCacheMisses считает промахи кэша баннеров
+	BannerCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "banner_cache_misses_total",
+		Help:      "Количество промахов кэша баннеров.",
+	})
+
+	// BannerSnapshotRefreshedAt хранит время последнего успешного обновления снимка баннеров
+	BannerSnapshotRefreshedAt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "banner_snapshot_refreshed_timestamp_seconds",
+		Help:      "Время последнего успешного обновления снимка баннеров в секундах Unix.",
+	})
+
+	// BannerSnapshotBanners хранит количество баннеров в снимке
+	BannerSnapshotBanners = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "banner_snapshot_banners",
+		Help:      "Количество баннеров в снимке.",
+	})
+
+	// BannerSnapshotRefreshErrors считает неудачные обновления снимка баннеров
+	BannerSnapshotRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "banner_snapshot_refresh_errors_total",
+		Help:      "Количество неудачных обновлений снимка баннеров.",
+	})
+
+	// BannerEventsTotal считает полученные события ленты изменений баннеров по типу изменения
+	BannerEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "banner_events_total",
+		Help:      "Количество событий ленты изменений баннеров.",
+	}, []string{"change"})
+
+	// BannerEventsDropped считает события, не доставленные подписчику из-за переполнения его буфера
+	BannerEventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "banner_events_dropped_total",
+		Help:      "Количество событий ленты изменений баннеров, не доставленных подписчику.",
+	}, []string{"subscriber"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		DBQueryDuration,
+		BannerCacheHits,
+		BannerCacheMisses,
+		BannerSnapshotRefreshedAt,
+		BannerSnapshotBanners,
+		BannerSnapshotRefreshErrors,
+		BannerEventsTotal,
+		BannerEventsDropped,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "banner_cache_hit_ratio",
+			Help:      "Доля попаданий в кэш баннеров с момента запуска.",
+		}, bannerCacheHitRatio),
+	)
+}
+
+// RegisterDB добавляет статистику пула соединений sql.DB
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveQuery записывает время выполнения метода репозитория, начатого в момент start
+func ObserveQuery(repository, method string, start time.Time) {
+	DBQueryDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+}
+
+// Handler возвращает HTTP обработчик метрик в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// bannerCacheHitRatio вычисляет долю попаданий в кэш баннеров
+func bannerCacheHitRatio() float64 {
+	hits := counterValue(BannerCacheHits)
+	total := hits + counterValue(BannerCacheMisses)
+	if total == 0 {
+		return 0
+	}
+	return hits / total
+}
+
+// counterValue читает текущее значение счетчика
+func counterValue(counter prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := counter.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"Avito_task/internal/auth"
+	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
+)
+
+// ErrAPIKeyNotFound возвращается при удалении несуществующего API ключа
+var ErrAPIKeyNotFound = errors.New("API ключ не найден")
+
+const (
+	// apiKeyBytes задает число случайных байт в ключе
+	apiKeyBytes = 32
+	// apiKeyPrefixLength задает длину начала ключа, которое хранится открыто для узнавания ключа в списке
+	apiKeyPrefixLength = len(entity.APIKeyPrefix) + 8
+	// apiKeyTokenTTL задает срок токена, выдаваемого на один запрос; он больше самого долгого ограничения времени запроса
+	apiKeyTokenTTL = 5 * time.Minute
+	// apiKeyTouchInterval задает, как часто обновляется время последнего использования ключа, чтобы не писать в базу на каждый запрос
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyUseCase выдает, проверяет и отзывает API ключи сервисов
+type APIKeyUseCase struct {
+	APIKeyRepository APIKeyRepository
+	TokenService     *auth.TokenService
+	Audit            *AuditUseCase
+}
+
+// NewAPIKeyUseCase создает новый экземпляр APIKeyUseCase; при audit == nil изменения не попадают в журнал аудита
+func NewAPIKeyUseCase(apiKeyRepo APIKeyRepository, tokenService *auth.TokenService, audit *AuditUseCase) *APIKeyUseCase {
+	return &APIKeyUseCase{
+		APIKeyRepository: apiKeyRepo,
+		TokenService:     tokenService,
+		Audit:            audit,
+	}
+}
+
+// hashAPIKey возвращает SHA-256 хеш ключа; ключи случайны и длинны, поэтому медленный хеш, как для паролей, не нужен
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey создает API ключ и возвращает его значение, которое больше нигде не сохраняется; требует разрешения user:manage.
+// Ключ не может получить разрешений, которых нет у создателя
+func (uc *APIKeyUseCase) CreateAPIKey(ctx context.Context, name string, scopes []string, expiresAt *time.Time, token string) (*entity.CreatedAPIKey, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: пустое имя ключа", ErrInvalidParams)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: срок действия ключа уже истек", ErrInvalidParams)
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	for _, scope := range scopes {
+		if !entity.IsPermission(scope) {
+			return nil, fmt.Errorf("%w: неизвестное разрешение %q", ErrInvalidParams, scope)
+		}
+		if !claims.HasPermission(scope) {
+			return nil, authError(auth.ErrPermissionDenied)
+		}
+	}
+
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("ошибка генерации ключа: %w", err)
+	}
+	value := entity.APIKeyPrefix + hex.EncodeToString(secret)
+
+	key := &entity.APIKey{
+		Name:      name,
+		Prefix:    value[:apiKeyPrefixLength],
+		KeyHash:   hashAPIKey(value),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedBy: claims.UserID,
+	}
+	if err := uc.APIKeyRepository.CreateAPIKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("ошибка при создании ключа: %w", err)
+	}
+
+	uc.Audit.Record(ctx, claims, entity.AuditCreate, entity.AuditEntityAPIKey, key.ID, nil, key)
+
+	return &entity.CreatedAPIKey{Key: value, APIKey: key}, nil
+}
+
+// GetAPIKeys возвращает все API ключи без их значений; требует разрешения user:manage
+func (uc *APIKeyUseCase) GetAPIKeys(ctx context.Context, token string) ([]*entity.APIKey, error) {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionUserManage); err != nil {
+		return nil, authError(err)
+	}
+
+	keys, err := uc.APIKeyRepository.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ключей: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey отзывает API ключ; требует разрешения user:manage. Ключ проверяется при каждом запросе, поэтому отзыв действует сразу
+func (uc *APIKeyUseCase) DeleteAPIKey(ctx context.Context, id int, token string) error {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return authError(err)
+	}
+
+	before, err := uc.APIKeyRepository.DeleteAPIKey(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w", ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении ключа: %w", err)
+	}
+
+	uc.Audit.Record(ctx, claims, entity.AuditDelete, entity.AuditEntityAPIKey, id, before, nil)
+
+	return nil
+}
+
+// AuthenticateAPIKey проверяет API ключ и выдает токен на время одного запроса с разрешениями ключа,
+// чтобы остальные сценарии проверяли ключи так же, как токены пользователей
+func (uc *APIKeyUseCase) AuthenticateAPIKey(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, entity.APIKeyPrefix) {
+		return "", fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+	}
+
+	key, err := uc.APIKeyRepository.GetAPIKeyByHash(ctx, hashAPIKey(value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка при получении ключа: %w", err)
+	}
+
+	now := time.Now()
+	if key.Expired(now) {
+		return "", fmt.Errorf("ошибка авторизации: срок действия ключа истек: %w", ErrUnauthorized)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		// Время использования справочное, поэтому ошибка его записи не мешает запросу
+		if err := uc.APIKeyRepository.TouchAPIKey(ctx, key.ID, now); err != nil {
+			logging.FromContext(ctx).Warn("ошибка записи времени использования API ключа",
+				"api_key_id", key.ID,
+				"error", err,
+			)
+		}
+	}
+
+	token, err := uc.TokenService.GenerateAPIKeyToken(key.ID, key.CreatedBy, key.Scopes, apiKeyTokenTTL)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при выдаче токена: %w", err)
+	}
+
+	return token, nil
+}
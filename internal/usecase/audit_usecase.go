@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"Avito_task/internal/auth"
+	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
+)
+
+// Ограничения размера выборки из журнала аудита
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditUseCase записывает изменения сущностей в журнал аудита и выдает его пользователям с разрешением audit:read
+type AuditUseCase struct {
+	AuditRepository AuditRepository
+	TokenService    *auth.TokenService
+}
+
+// NewAuditUseCase создает новый экземпляр AuditUseCase
+func NewAuditUseCase(auditRepo AuditRepository, tokenService *auth.TokenService) *AuditUseCase {
+	return &AuditUseCase{
+		AuditRepository: auditRepo,
+		TokenService:    tokenService,
+	}
+}
+
+// Record фиксирует действие владельца токена actor над сущностью с состояниями до и после него;
+// для токена API ключа вместе с создателем ключа записывается и сам ключ.
+// Изменение к этому моменту уже сохранено, поэтому ошибка записи только логируется.
+// Вызов на nil ничего не делает, что позволяет запускать сценарии без журнала
+func (uc *AuditUseCase) Record(ctx context.Context, actor *auth.Claims, action, entityName string, entityID int, before, after interface{}) {
+	if uc == nil {
+		return
+	}
+
+	diff, err := entity.AuditDiff(before, after)
+	if err == nil {
+		err = uc.AuditRepository.CreateAuditRecord(ctx, &entity.AuditRecord{
+			ActorID:  actor.UserID,
+			APIKeyID: actor.APIKeyID,
+			Action:   action,
+			Entity:   entityName,
+			EntityID: entityID,
+			Diff:     diff,
+		})
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("ошибка записи в журнал аудита",
+			"action", action,
+			"entity", entityName,
+			"entity_id", entityID,
+			"error", err,
+		)
+	}
+}
+
+// GetAuditLog получает записи журнала аудита по фильтру
+func (uc *AuditUseCase) GetAuditLog(ctx context.Context, filter entity.AuditFilter, token string) ([]*entity.AuditRecord, error) {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionAuditRead); err != nil {
+		return nil, authError(err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditLimit || filter.ActorID < 0 {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	records, err := uc.AuditRepository.GetAuditRecords(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении журнала аудита: %w", err)
+	}
+	if records == nil {
+		records = []*entity.AuditRecord{}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// BannerCacheKey идентифицирует баннер пользователя в кэше по тегу и фиче
+type BannerCacheKey struct {
+	TagID     int
+	FeatureID int
+}
+
+// BannerCache описывает кэш баннеров пользователей. Записи живут не дольше TTL, а изменения баннеров
+// удаляют затронутые записи сразу; общее хранилище кэша делает удаление видимым всем репликам сервиса
+type BannerCache interface {
+	// Get возвращает баннер из кэша; ok == false означает промах
+	Get(ctx context.Context, key BannerCacheKey) (banner *entity.Banner, ok bool, err error)
+	// Set сохраняет баннер в кэш на время TTL
+	Set(ctx context.Context, key BannerCacheKey, banner *entity.Banner) error
+	// Invalidate удаляет записи из кэша
+	Invalidate(ctx context.Context, keys ...BannerCacheKey) error
+	// TTL возвращает срок жизни записей
+	TTL() time.Duration
+}
+
+// bannerCacheKeys возвращает ключи кэша всех пар (тег, фича) баннеров без повторов; nil баннеры пропускаются
+func bannerCacheKeys(banners ...*entity.Banner) []BannerCacheKey {
+	seen := make(map[BannerCacheKey]bool)
+	var keys []BannerCacheKey
+	for _, banner := range banners {
+		if banner == nil {
+			continue
+		}
+		for _, tagID := range banner.TagIDs {
+			key := BannerCacheKey{TagID: tagID, FeatureID: banner.FeatureID}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/metrics"
+)
+
+// bannerEventBuffer задает размер буфера событий каждого подписчика
+const bannerEventBuffer = 256
+
+// BannerEventHandler обрабатывает событие изменения баннера
+type BannerEventHandler func(ctx context.Context, event entity.BannerEvent)
+
+// bannerSubscriber получает события в своей горутине, чтобы медленный подписчик не задерживал остальных
+type bannerSubscriber struct {
+	name    string
+	handler BannerEventHandler
+	events  chan entity.BannerEvent
+	lost    atomic.Bool
+}
+
+// BannerEventBus раздает события изменения баннеров подписчикам внутри процесса: кэшу, снимку, статистике, вебхукам.
+// Если буфер подписчика переполнен, событие отбрасывается, а подписчик после разбора буфера получает BannerEventResync
+type BannerEventBus struct {
+	mu          sync.RWMutex
+	subscribers []*bannerSubscriber
+	// running учитывает горутины подписчиков, чтобы при остановке дождаться их через Wait
+	running sync.WaitGroup
+}
+
+// NewBannerEventBus создает новый экземпляр BannerEventBus
+func NewBannerEventBus() *BannerEventBus {
+	return &BannerEventBus{}
+}
+
+// Subscribe добавляет подписчика; его обработчик вызывается последовательно до отмены контекста
+func (bus *BannerEventBus) Subscribe(ctx context.Context, name string, handler BannerEventHandler) {
+	sub := &bannerSubscriber{
+		name:    name,
+		handler: handler,
+		events:  make(chan entity.BannerEvent, bannerEventBuffer),
+	}
+
+	bus.mu.Lock()
+	bus.subscribers = append(bus.subscribers, sub)
+	bus.mu.Unlock()
+
+	bus.running.Add(1)
+	go func() {
+		defer bus.running.Done()
+		sub.run(ctx)
+	}()
+}
+
+// Wait дожидается остановки подписчиков после отмены их контекстов, в том числе завершения текущих обработчиков
+func (bus *BannerEventBus) Wait() {
+	bus.running.Wait()
+}
+
+// Publish передает событие всем подписчикам, не дожидаясь обработки
+func (bus *BannerEventBus) Publish(event entity.BannerEvent) {
+	metrics.BannerEventsTotal.WithLabelValues(event.Change).Inc()
+
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	for _, sub := range bus.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.lost.Store(true)
+			metrics.BannerEventsDropped.WithLabelValues(sub.name).Inc()
+			slog.Warn("буфер подписчика ленты изменений баннеров переполнен", "subscriber", sub.name, "banner_id", event.BannerID)
+		}
+	}
+}
+
+// run вызывает обработчик для событий из буфера и сообщает о потерянных событиях, когда буфер разобран
+func (sub *bannerSubscriber) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-sub.events:
+			sub.handler(ctx, event)
+		}
+
+		if len(sub.events) == 0 && sub.lost.Swap(false) {
+			sub.handler(ctx, entity.BannerEvent{Change: entity.BannerEventResync})
+		}
+	}
+}
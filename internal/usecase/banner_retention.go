@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// BannerRetention периодически окончательно удаляет баннеры, которые были удалены мягко раньше срока хранения
+type BannerRetention struct {
+	BannerRepository BannerRepository
+	Period           time.Duration
+	Interval         time.Duration
+}
+
+// NewBannerRetention создает новый экземпляр BannerRetention
+func NewBannerRetention(bannerRepo BannerRepository, period, interval time.Duration) *BannerRetention {
+	return &BannerRetention{
+		BannerRepository: bannerRepo,
+		Period:           period,
+		Interval:         interval,
+	}
+}
+
+// Run удаляет устаревшие баннеры с заданным интервалом до отмены контекста
+func (r *BannerRetention) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Purge(ctx, time.Now()); err != nil {
+			slog.Error("ошибка при удалении устаревших баннеров", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge окончательно удаляет баннеры, помеченные удаленными раньше чем за Period до момента now
+func (r *BannerRetention) Purge(ctx context.Context, now time.Time) (int, error) {
+	purged, err := r.BannerRepository.PurgeDeletedBanners(ctx, now.Add(-r.Period))
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		slog.Info("удалены устаревшие баннеры", "count", purged)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"Avito_task/internal/auth"
+	"Avito_task/internal/entity"
+)
+
+// BannerScheduler периодически собирает предстоящие включения и выключения баннеров
+type BannerScheduler struct {
+	BannerRepository BannerRepository
+	TokenService     *auth.TokenService
+	Interval         time.Duration
+	Horizon          time.Duration
+
+	mu     sync.RWMutex
+	events []entity.ScheduleEvent
+}
+
+// NewBannerScheduler создает новый экземпляр BannerScheduler
+func NewBannerScheduler(bannerRepo BannerRepository, tokenService *auth.TokenService, interval, horizon time.Duration) *BannerScheduler {
+	return &BannerScheduler{
+		BannerRepository: bannerRepo,
+		TokenService:     tokenService,
+		Interval:         interval,
+		Horizon:          horizon,
+	}
+}
+
+// Run обновляет расписание с заданным интервалом до отмены контекста
+func (s *BannerScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(ctx, time.Now()); err != nil {
+			slog.Error("ошибка при обновлении расписания баннеров", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh перечитывает из репозитория события расписания в пределах горизонта от момента now
+func (s *BannerScheduler) Refresh(ctx context.Context, now time.Time) error {
+	banners, err := s.BannerRepository.GetScheduledBanners(ctx, now, now.Add(s.Horizon))
+	if err != nil {
+		return err
+	}
+
+	events := buildScheduleEvents(banners, now, now.Add(s.Horizon))
+
+	s.mu.Lock()
+	s.events = events
+	s.mu.Unlock()
+
+	return nil
+}
+
+// GetSchedule возвращает предстоящие события расписания, еще не наступившие к текущему моменту
+func (s *BannerScheduler) GetSchedule(token string) ([]entity.ScheduleEvent, error) {
+	// Проверка разрешения токена
+	if _, err := s.TokenService.Authorize(token, entity.PermissionBannerRead); err != nil {
+		return nil, authError(err)
+	}
+
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	upcoming := make([]entity.ScheduleEvent, 0, len(s.events))
+	for _, event := range s.events {
+		if event.At.After(now) {
+			upcoming = append(upcoming, event)
+		}
+	}
+
+	return upcoming, nil
+}
+
+// buildScheduleEvents раскладывает окна показа баннеров на события в интервале (from, to]
+func buildScheduleEvents(banners []*entity.Banner, from, to time.Time) []entity.ScheduleEvent {
+	var events []entity.ScheduleEvent
+	inRange := func(t *time.Time) bool {
+		return t != nil && t.After(from) && !t.After(to)
+	}
+
+	for _, banner := range banners {
+		if inRange(banner.ActiveFrom) {
+			events = append(events, entity.ScheduleEvent{
+				BannerID:  banner.ID,
+				FeatureID: banner.FeatureID,
+				TagIDs:    banner.TagIDs,
+				Type:      entity.ScheduleActivation,
+				At:        *banner.ActiveFrom,
+			})
+		}
+		if inRange(banner.ActiveUntil) {
+			events = append(events, entity.ScheduleEvent{
+				BannerID:  banner.ID,
+				FeatureID: banner.FeatureID,
+				TagIDs:    banner.TagIDs,
+				Type:      entity.ScheduleDeactivation,
+				At:        *banner.ActiveUntil,
+			})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+
+	return events
+}
@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"Avito_task/internal/entity"
+	"Avito_task/internal/metrics"
+)
+
+// bannerIndex является неизменяемым индексом баннеров по парам (тег, фича).
+// Баннеры каждой пары упорядочены так же, как в запросе репозитория: сначала включенные, затем более новые
+type bannerIndex struct {
+	banners map[BannerCacheKey][]*entity.Banner
+}
+
+// BannerSnapshot хранит в памяти все неудаленные баннеры и отвечает на запросы баннеров пользователей без обращения к базе данных.
+// Снимок обновляется целиком с заданным интервалом и по запросу; при ошибке обновления продолжает работать прежний снимок
+type BannerSnapshot struct {
+	BannerRepository BannerRepository
+	Interval         time.Duration
+
+	index   atomic.Pointer[bannerIndex]
+	refresh chan struct{}
+}
+
+// NewBannerSnapshot создает новый экземпляр BannerSnapshot; до первого Refresh снимок пуст и не используется
+func NewBannerSnapshot(bannerRepo BannerRepository, interval time.Duration) *BannerSnapshot {
+	return &BannerSnapshot{
+		BannerRepository: bannerRepo,
+		Interval:         interval,
+		refresh:          make(chan struct{}, 1),
+	}
+}
+
+// Get возвращает баннер пары (тег, фича), окно показа которого включает момент now.
+// loaded == false означает, что снимок еще не загружен; banner == nil при loaded == true означает, что баннера нет
+func (s *BannerSnapshot) Get(key BannerCacheKey, now time.Time) (banner *entity.Banner, loaded bool) {
+	index := s.index.Load()
+	if index == nil {
+		return nil, false
+	}
+
+	for _, banner := range index.banners[key] {
+		if banner.IsLive(now) {
+			return banner, true
+		}
+	}
+	return nil, true
+}
+
+// Refresh загружает все баннеры из репозитория и атомарно заменяет ими снимок
+func (s *BannerSnapshot) Refresh(ctx context.Context) error {
+	banners, err := s.BannerRepository.GetSnapshotBanners(ctx)
+	if err != nil {
+		metrics.BannerSnapshotRefreshErrors.Inc()
+		return fmt.Errorf("ошибка загрузки снимка баннеров: %w", err)
+	}
+
+	index := &bannerIndex{banners: make(map[BannerCacheKey][]*entity.Banner)}
+	for _, banner := range banners {
+		for _, key := range bannerCacheKeys(banner) {
+			index.banners[key] = append(index.banners[key], banner)
+		}
+	}
+	for _, candidates := range index.banners {
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].IsActive != candidates[j].IsActive {
+				return candidates[i].IsActive
+			}
+			return candidates[i].ID > candidates[j].ID
+		})
+	}
+	s.index.Store(index)
+
+	metrics.BannerSnapshotRefreshedAt.Set(float64(time.Now().Unix()))
+	metrics.BannerSnapshotBanners.Set(float64(len(banners)))
+
+	return nil
+}
+
+// Notify просит обновить снимок, не дожидаясь интервала; запросы, поступившие во время обновления, объединяются в одно
+func (s *BannerSnapshot) Notify() {
+	if s == nil {
+		return
+	}
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Run обновляет снимок с заданным интервалом и по Notify до отмены контекста
+func (s *BannerSnapshot) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.refresh:
+		}
+
+		if err := s.Refresh(ctx); err != nil {
+			slog.Error("ошибка при обновлении снимка баннеров", "error", err)
+		}
+	}
+}
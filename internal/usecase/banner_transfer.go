@@ -0,0 +1,423 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+const (
+	// exportPageSize задает размер страницы, которыми баннеры читаются из репозитория при экспорте
+	exportPageSize = 500
+	// maxImportLineSize ограничивает длину строки NDJSON при импорте
+	maxImportLineSize = 1 << 20
+)
+
+// bannerCSVHeader содержит колонки CSV файлов импорта и экспорта
+var bannerCSVHeader = []string{"banner_id", "feature_id", "tag_ids", "content", "is_active", "active_from", "active_until"}
+
+// errImportDryRun откатывает транзакцию пробного импорта
+var errImportDryRun = errors.New("пробный импорт")
+
+// importLine содержит баннер из строки файла импорта
+type importLine struct {
+	number int
+	banner *entity.Banner
+}
+
+// ExportBanners пишет в w все неудаленные баннеры в формате format, читая их из репозитория страницами
+func (uc *BannerUseCase) ExportBanners(ctx context.Context, w io.Writer, format string, token string) error {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionBannerRead); err != nil {
+		return authError(err)
+	}
+
+	if format != entity.BannerFormatNDJSON && format != entity.BannerFormatCSV {
+		return fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	buffered := bufio.NewWriter(w)
+	var writeRecord func(record entity.BannerRecord) error
+	switch format {
+	case entity.BannerFormatNDJSON:
+		encoder := json.NewEncoder(buffered)
+		writeRecord = func(record entity.BannerRecord) error {
+			return encoder.Encode(record)
+		}
+	case entity.BannerFormatCSV:
+		csvWriter := csv.NewWriter(buffered)
+		if err := csvWriter.Write(bannerCSVHeader); err != nil {
+			return err
+		}
+		writeRecord = func(record entity.BannerRecord) error {
+			if err := csvWriter.Write(bannerRecordToCSV(record)); err != nil {
+				return err
+			}
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	}
+
+	query := entity.BannerListQuery{Sort: entity.BannerSortID, Limit: exportPageSize}
+	for {
+		banners, err := uc.BannerRepository.GetAllBanners(ctx, query)
+		if err != nil {
+			return fmt.Errorf("ошибка при получении баннеров: %w", err)
+		}
+
+		for _, banner := range banners {
+			if err := writeRecord(bannerToRecord(banner)); err != nil {
+				return err
+			}
+		}
+		// Каждая страница сразу уходит клиенту
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+
+		if len(banners) < exportPageSize {
+			return nil
+		}
+		query.After = &entity.BannerCursor{ID: banners[len(banners)-1].ID}
+	}
+}
+
+// ImportBanners создает или обновляет баннеры из r в одной транзакции.
+// Баннер обновляется, если у существующего баннера та же фича и тот же набор тегов, иначе создается новый;
+// banner_id из файла не используется, чтобы файлы можно было переносить между окружениями.
+// При ошибках в строках не применяется ни одна строка; при dryRun транзакция откатывается в любом случае
+func (uc *BannerUseCase) ImportBanners(ctx context.Context, r io.Reader, format string, dryRun bool, token string) (*entity.BannerImportReport, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionBannerWrite)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	var lines []importLine
+	var lineErrors []entity.BannerImportError
+	switch format {
+	case entity.BannerFormatNDJSON:
+		lines, lineErrors, err = readNDJSONBanners(r)
+	case entity.BannerFormatCSV:
+		lines, lineErrors, err = readCSVBanners(r)
+	default:
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &entity.BannerImportReport{DryRun: dryRun, Errors: lineErrors}
+	if report.Errors == nil {
+		report.Errors = []entity.BannerImportError{}
+	}
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	// Изменения для журнала аудита записываются только после фиксации транзакции
+	type change struct {
+		action        string
+		before, after *entity.Banner
+	}
+	var changes []change
+
+	err = uc.BannerRepository.InTransaction(ctx, func(ctx context.Context) error {
+		for _, line := range lines {
+			existing, err := findImportTarget(ctx, uc.BannerRepository, line.banner)
+			if err != nil {
+				return fmt.Errorf("строка %d: %w", line.number, err)
+			}
+
+			if existing == nil {
+				if err := uc.BannerRepository.CreateBanner(ctx, line.banner); err != nil {
+					return fmt.Errorf("строка %d: %w", line.number, err)
+				}
+				report.Created++
+				changes = append(changes, change{entity.AuditCreate, nil, line.banner})
+				continue
+			}
+
+			// Файл не содержит вариантов содержимого, поэтому варианты существующего баннера сохраняются
+			line.banner.ID = existing.ID
+			line.banner.Variants = existing.Variants
+			if err := uc.BannerRepository.UpdateBanner(ctx, line.banner); err != nil {
+				return fmt.Errorf("строка %d: %w", line.number, err)
+			}
+			report.Updated++
+			changes = append(changes, change{entity.AuditUpdate, existing, line.banner})
+		}
+
+		if dryRun {
+			return errImportDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportDryRun) {
+		return nil, fmt.Errorf("ошибка при импорте баннеров: %w", err)
+	}
+
+	if !dryRun {
+		changed := make([]*entity.Banner, 0, len(changes))
+		for _, c := range changes {
+			changed = append(changed, c.after)
+		}
+		// Импорт обновляет только баннеры с той же фичей и тем же набором тегов, поэтому ключей новых состояний достаточно
+		uc.invalidateCache(ctx, changed...)
+
+		for _, c := range changes {
+			var before interface{}
+			if c.before != nil {
+				before = c.before
+			}
+			uc.Audit.Record(ctx, claims, c.action, entity.AuditEntityBanner, c.after.ID, before, c.after)
+		}
+	}
+
+	return report, nil
+}
+
+// findImportTarget ищет неудаленный баннер с той же фичей и тем же набором тегов; если его нет, возвращает nil
+func findImportTarget(ctx context.Context, repo BannerRepository, banner *entity.Banner) (*entity.Banner, error) {
+	target, err := repo.GetBannerByFeatureAndTags(ctx, banner.FeatureID, banner.TagIDs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return target, err
+}
+
+// readNDJSONBanners читает баннеры по одному JSON объекту в строке; пустые строки пропускаются
+func readNDJSONBanners(r io.Reader) ([]importLine, []entity.BannerImportError, error) {
+	var lines []importLine
+	var lineErrors []entity.BannerImportError
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record entity.BannerRecord
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			lineErrors = append(lineErrors, entity.BannerImportError{Line: number, Error: err.Error()})
+			continue
+		}
+
+		banner, err := recordToBanner(record)
+		if err != nil {
+			lineErrors = append(lineErrors, entity.BannerImportError{Line: number, Error: err.Error()})
+			continue
+		}
+		lines = append(lines, importLine{number: number, banner: banner})
+	}
+
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		lineErrors = append(lineErrors, entity.BannerImportError{Line: number + 1, Error: "строка слишком длинная"})
+		return lines, lineErrors, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("ошибка чтения файла импорта: %w", err)
+	}
+
+	return lines, lineErrors, nil
+}
+
+// readCSVBanners читает баннеры из CSV с заголовком; порядок колонок может быть любым
+func readCSVBanners(r io.Reader) ([]importLine, []entity.BannerImportError, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, []entity.BannerImportError{{Line: 1, Error: err.Error()}}, nil
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"feature_id", "tag_ids", "content"} {
+		if _, ok := columns[required]; !ok {
+			return nil, []entity.BannerImportError{{Line: 1, Error: "нет колонки " + required}}, nil
+		}
+	}
+
+	var lines []importLine
+	var lineErrors []entity.BannerImportError
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			lineErrors = append(lineErrors, entity.BannerImportError{Line: parseErr.Line, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка чтения файла импорта: %w", err)
+		}
+
+		number, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		record, err := csvToBannerRecord(field)
+		if err == nil {
+			var banner *entity.Banner
+			if banner, err = recordToBanner(record); err == nil {
+				lines = append(lines, importLine{number: number, banner: banner})
+				continue
+			}
+		}
+		lineErrors = append(lineErrors, entity.BannerImportError{Line: number, Error: err.Error()})
+	}
+
+	return lines, lineErrors, nil
+}
+
+// csvToBannerRecord разбирает поля строки CSV
+func csvToBannerRecord(field func(name string) string) (entity.BannerRecord, error) {
+	var record entity.BannerRecord
+	var err error
+
+	if value := field("banner_id"); value != "" {
+		if record.ID, err = strconv.Atoi(value); err != nil {
+			return record, fmt.Errorf("некорректный banner_id %q", value)
+		}
+	}
+	if record.FeatureID, err = strconv.Atoi(field("feature_id")); err != nil {
+		return record, fmt.Errorf("некорректный feature_id %q", field("feature_id"))
+	}
+	for _, value := range strings.Split(field("tag_ids"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		tagID, err := strconv.Atoi(value)
+		if err != nil {
+			return record, fmt.Errorf("некорректный tag_id %q", value)
+		}
+		record.TagIDs = append(record.TagIDs, tagID)
+	}
+	record.Content = json.RawMessage(field("content"))
+	if value := field("is_active"); value != "" {
+		if record.IsActive, err = strconv.ParseBool(value); err != nil {
+			return record, fmt.Errorf("некорректный is_active %q", value)
+		}
+	}
+	if record.ActiveFrom, err = parseOptionalTime(field("active_from")); err != nil {
+		return record, fmt.Errorf("некорректный active_from: %w", err)
+	}
+	if record.ActiveUntil, err = parseOptionalTime(field("active_until")); err != nil {
+		return record, fmt.Errorf("некорректный active_until: %w", err)
+	}
+
+	return record, nil
+}
+
+// recordToBanner проверяет запись файла импорта по тем же правилам, что и CreateBanner
+func recordToBanner(record entity.BannerRecord) (*entity.Banner, error) {
+	if record.FeatureID <= 0 {
+		return nil, errors.New("feature_id должен быть положительным")
+	}
+	if len(record.TagIDs) == 0 {
+		return nil, errors.New("нужен хотя бы один tag_id")
+	}
+	for _, tagID := range record.TagIDs {
+		if tagID <= 0 {
+			return nil, errors.New("tag_id должен быть положительным")
+		}
+	}
+	if record.ActiveFrom != nil && record.ActiveUntil != nil && !record.ActiveFrom.Before(*record.ActiveUntil) {
+		return nil, errors.New("active_from должен быть раньше active_until")
+	}
+
+	var content map[string]interface{}
+	if err := json.Unmarshal(record.Content, &content); err != nil || content == nil {
+		return nil, errors.New("content должен быть JSON объектом")
+	}
+	jsonStructure, err := entity.MapToJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.Banner{
+		JSONStructure: jsonStructure,
+		FeatureID:     record.FeatureID,
+		TagIDs:        record.TagIDs,
+		IsActive:      record.IsActive,
+		ActiveFrom:    record.ActiveFrom,
+		ActiveUntil:   record.ActiveUntil,
+	}, nil
+}
+
+// bannerToRecord преобразует баннер в запись файла экспорта
+func bannerToRecord(banner *entity.Banner) entity.BannerRecord {
+	return entity.BannerRecord{
+		ID:          banner.ID,
+		FeatureID:   banner.FeatureID,
+		TagIDs:      banner.TagIDs,
+		Content:     json.RawMessage(banner.JSONStructure),
+		IsActive:    banner.IsActive,
+		ActiveFrom:  banner.ActiveFrom,
+		ActiveUntil: banner.ActiveUntil,
+	}
+}
+
+// bannerRecordToCSV преобразует запись файла экспорта в поля строки CSV в порядке bannerCSVHeader
+func bannerRecordToCSV(record entity.BannerRecord) []string {
+	tagIDs := make([]string, len(record.TagIDs))
+	for i, tagID := range record.TagIDs {
+		tagIDs[i] = strconv.Itoa(tagID)
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+
+	return []string{
+		strconv.Itoa(record.ID),
+		strconv.Itoa(record.FeatureID),
+		strings.Join(tagIDs, ","),
+		string(record.Content),
+		strconv.FormatBool(record.IsActive),
+		formatTime(record.ActiveFrom),
+		formatTime(record.ActiveUntil),
+	}
+}
+
+// parseOptionalTime разбирает время в формате RFC 3339; пустая строка означает отсутствие значения
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -1,16 +1,24 @@
 package usecase
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"Avito_task/internal/auth"
-	"Avito_task/internal/db"
 	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
+	"Avito_task/internal/metrics"
 )
 
 var (
 	ErrUnauthorized   = errors.New("неавторизованный запрос")
+	ErrForbidden      = errors.New("недостаточно прав")
 	ErrInvalidParams  = errors.New("неверные параметры")
 	ErrBannerNotFound = errors.New("баннер не найден")
 	ErrCreateBanner   = errors.New("ошибка при создании баннера")
@@ -18,62 +26,226 @@ var (
 	ErrDeleteBanner   = errors.New("ошибка при удалении баннера")
 )
 
+// authError превращает ошибку проверки токена в ErrForbidden для валидного
+// токена без нужного разрешения и в ErrUnauthorized для отсутствующего или неверного токена
+func authError(err error) error {
+	if errors.Is(err, auth.ErrPermissionDenied) {
+		return fmt.Errorf("ошибка авторизации: %w", ErrForbidden)
+	}
+	return fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+}
+
 // BannerUseCase представляет интерфейс для работы с баннерами
 type BannerUseCase struct {
-	BannerRepository db.BannerRepository
+	BannerRepository BannerRepository
+	GrantRepository  GrantRepository
 	TokenService     *auth.TokenService
+	BannerCache      BannerCache
+	Snapshot         *BannerSnapshot
+	Audit            *AuditUseCase
 }
 
-// NewBannerUseCase создает новый экземпляр BannerUseCase
-func NewBannerUseCase(bannerRepo db.BannerRepository, tokenService auth.TokenService) *BannerUseCase {
+// NewBannerUseCase создает новый экземпляр BannerUseCase; при bannerCache == nil кэш не используется,
+// при snapshot == nil баннеры пользователей читаются из кэша и репозитория,
+// при audit == nil изменения не попадают в журнал аудита, при grantRepo == nil учитываются только разрешения ролей
+func NewBannerUseCase(bannerRepo BannerRepository, grantRepo GrantRepository, tokenService *auth.TokenService, bannerCache BannerCache, snapshot *BannerSnapshot, audit *AuditUseCase) *BannerUseCase {
 	return &BannerUseCase{
 		BannerRepository: bannerRepo,
-		TokenService:     &tokenService,
+		GrantRepository:  grantRepo,
+		TokenService:     tokenService,
+		BannerCache:      bannerCache,
+		Snapshot:         snapshot,
+		Audit:            audit,
+	}
+}
+
+// CacheTTL возвращает, сколько баннер пользователя может отставать от базы данных из-за кэша или снимка; 0 означает, что кэша нет
+func (uc *BannerUseCase) CacheTTL() time.Duration {
+	if uc.Snapshot != nil {
+		return uc.Snapshot.Interval
+	}
+	if uc.BannerCache == nil {
+		return 0
 	}
+	return uc.BannerCache.TTL()
 }
 
-// GetUserBanner получает баннер для пользователя по заданным параметрам
-func (uc *BannerUseCase) GetUserBanner(id int, token string) (*entity.Banner, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
+// GetUserBanner получает баннер для пользователя по тегу и фиче с учетом окна показа.
+// Без useLastRevision баннер может быть взят из снимка или кэша и отставать от базы данных на интервал обновления снимка или TTL.
+func (uc *BannerUseCase) GetUserBanner(ctx context.Context, tagID, featureID int, useLastRevision bool, token string) (*entity.Banner, error) {
+	// Проверка токена пользователя
+	claims, err := uc.TokenService.ParseToken(token)
+	if err != nil {
 		return nil, fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
 	}
 
-	// Получаем баннер из репозитория с флагом useLastRevision
-	banner, err := uc.BannerRepository.GetBannerByID(id, true) // Используем флаг true для получения самой актуальной информации
+	if tagID == 0 || featureID == 0 {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	banner, err := uc.loadUserBanner(ctx, tagID, featureID, useLastRevision, time.Now())
 	if err != nil {
+		return nil, err
+	}
+
+	// Выключенные баннеры доступны только тем, кто может просматривать все баннеры
+	if !banner.IsActive && !claims.HasPermission(entity.PermissionBannerRead) {
 		return nil, fmt.Errorf("%w", ErrBannerNotFound)
 	}
 
-	return banner, nil
+	// Выбираем вариант содержимого, закрепленный за пользователем или API ключом; закэшированный баннер не изменяем
+	served := *banner
+	var variant *entity.BannerVariant
+	if claims.APIKeyID != 0 {
+		variant = banner.PickAPIKeyVariant(claims.APIKeyID)
+	} else {
+		variant = banner.PickVariant(claims.UserID)
+	}
+	if variant != nil {
+		served.JSONStructure = variant.JSONStructure
+		served.VariantID = variant.ID
+	}
+	served.Variants = nil
+
+	return &served, nil
 }
 
-// GetAllBanners получает все баннеры с учетом фильтров по фиче, тегу, лимиту и оффсету
-func (uc *BannerUseCase) GetAllBanners(tagID, featureID, limit, offset int, token string) ([]*entity.Banner, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
+// GetUserBannerByID получает последнюю версию баннера по его ID для устаревшего маршрута GET /user_banner/{id}.
+// Маршрут сохраняет прежний контракт: он доступен только тем, кто может просматривать все баннеры,
+// а любой отказ в доступе возвращается как ErrUnauthorized
+func (uc *BannerUseCase) GetUserBannerByID(ctx context.Context, id int, token string) (*entity.Banner, error) {
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionBannerRead); err != nil {
 		return nil, fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
 	}
 
-	// Проверяем, что limit и offset не равны нулю
-	if limit == 0 || offset < 0 {
+	banner, err := uc.BannerRepository.GetBannerByID(ctx, id, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w", ErrBannerNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении баннера: %w", err)
+	}
+
+	return banner, nil
+}
+
+// loadUserBanner получает баннер, окно показа которого включает момент now, из снимка, кэша или репозитория.
+// Загруженный снимок отвечает без обращения к базе данных; ошибка кэша не мешает чтению: баннер берется из репозитория
+func (uc *BannerUseCase) loadUserBanner(ctx context.Context, tagID, featureID int, useLastRevision bool, now time.Time) (*entity.Banner, error) {
+	key := BannerCacheKey{TagID: tagID, FeatureID: featureID}
+	if uc.Snapshot != nil && !useLastRevision {
+		if banner, loaded := uc.Snapshot.Get(key, now); loaded {
+			if banner == nil {
+				return nil, fmt.Errorf("%w", ErrBannerNotFound)
+			}
+			return banner, nil
+		}
+	}
+	if uc.BannerCache != nil && !useLastRevision {
+		banner, ok, err := uc.BannerCache.Get(ctx, key)
+		if err != nil {
+			logging.FromContext(ctx).Warn("ошибка чтения кэша баннеров", "error", err)
+		}
+		if ok && banner.IsLive(now) {
+			metrics.BannerCacheHits.Inc()
+			return banner, nil
+		}
+		metrics.BannerCacheMisses.Inc()
+	}
+
+	banner, err := uc.BannerRepository.GetUserBanner(ctx, tagID, featureID, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w", ErrBannerNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении баннера: %w", err)
+	}
+
+	if uc.BannerCache != nil {
+		if err := uc.BannerCache.Set(ctx, key, banner); err != nil {
+			logging.FromContext(ctx).Warn("ошибка записи в кэш баннеров", "error", err)
+		}
+	}
+
+	return banner, nil
+}
+
+// invalidateCache удаляет из кэша пары (тег, фича) баннеров до и после изменения и просит обновить снимок,
+// чтобы пользователи сразу видели изменение. Изменение к этому моменту уже сохранено, поэтому удаление не прерывается
+// вместе с запросом, а его ошибка только логируется: тогда баннер устареет не позже TTL.
+// Чтение, начатое до изменения, тоже может вернуть в кэш старый баннер на время TTL
+func (uc *BannerUseCase) invalidateCache(ctx context.Context, banners ...*entity.Banner) {
+	uc.Snapshot.Notify()
+	if uc.BannerCache == nil {
+		return
+	}
+	keys := bannerCacheKeys(banners...)
+	if err := uc.BannerCache.Invalidate(context.WithoutCancel(ctx), keys...); err != nil {
+		logging.FromContext(ctx).Error("ошибка удаления баннеров из кэша", "keys", len(keys), "error", err)
+	}
+}
+
+// GetAllBanners получает страницу баннеров с учетом фильтров и сортировки, продолжая список после курсора cursor
+func (uc *BannerUseCase) GetAllBanners(ctx context.Context, query entity.BannerListQuery, cursor string, token string) (*entity.BannerPage, error) {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionBannerRead); err != nil {
+		return nil, authError(err)
+	}
+
+	// Проверяем лимит, оффсет и поле сортировки
+	if query.Limit <= 0 || query.Offset < 0 {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+	if query.Sort == "" {
+		query.Sort = entity.BannerSortID
+	}
+	if query.Sort != entity.BannerSortID && query.Sort != entity.BannerSortCreatedAt && query.Sort != entity.BannerSortUpdatedAt {
 		return nil, fmt.Errorf("%w", ErrInvalidParams)
 	}
 
-	// Получаем все баннеры из репозитория с учетом фильтров
-	banners, err := uc.BannerRepository.GetAllBanners(tagID, featureID, limit, offset)
+	// Курсор заменяет оффсет и действителен только для той же сортировки
+	if cursor != "" {
+		if query.Offset != 0 {
+			return nil, fmt.Errorf("%w", ErrInvalidParams)
+		}
+		after, err := decodeBannerCursor(cursor, query.Sort, query.Desc)
+		if err != nil {
+			return nil, err
+		}
+		query.After = after
+	}
+
+	total, err := uc.BannerRepository.CountBanners(ctx, query.BannerFilter)
 	if err != nil {
-		return nil, fmt.Errorf("%w", ErrBannerNotFound)
+		return nil, fmt.Errorf("ошибка при подсчете баннеров: %w", err)
+	}
+
+	// Лишний баннер показывает, что за страницей есть продолжение
+	limit := query.Limit
+	query.Limit++
+	banners, err := uc.BannerRepository.GetAllBanners(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении баннеров: %w", err)
 	}
 
-	return banners, nil
+	page := &entity.BannerPage{Items: banners, Total: total}
+	if len(banners) > limit {
+		page.Items = banners[:limit]
+		page.NextCursor = encodeBannerCursor(page.Items[limit-1], query.Sort, query.Desc)
+	}
+	if page.Items == nil {
+		page.Items = []*entity.Banner{}
+	}
+
+	return page, nil
 }
 
 // CreateBanner создает новый баннер
-func (uc *BannerUseCase) CreateBanner(tagIDs []int, featureID int, content map[string]interface{}, isActive bool, token string) (*entity.Banner, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return nil, fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+func (uc *BannerUseCase) CreateBanner(ctx context.Context, tagIDs []int, featureID int, content map[string]interface{}, isActive bool, activeFrom, activeUntil *time.Time, variants []entity.BannerVariantRequest, token string) (*entity.Banner, error) {
+	// Проверка разрешения токена
+	claims, access, err := uc.authorizeFeatures(ctx, token, entity.PermissionBannerWrite)
+	if err != nil {
+		return nil, err
 	}
 
 	// Проверяем, что tagIDs не пустой и featureID не равен нулю
@@ -81,34 +253,56 @@ func (uc *BannerUseCase) CreateBanner(tagIDs []int, featureID int, content map[s
 		return nil, fmt.Errorf("%w", ErrInvalidParams)
 	}
 
+	if !access.allows(featureID) {
+		return nil, authError(auth.ErrPermissionDenied)
+	}
+
+	// Окно показа не может заканчиваться раньше, чем начинается
+	if activeFrom != nil && activeUntil != nil && !activeFrom.Before(*activeUntil) {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
 	// Преобразуем содержимое баннера в формат JSON
 	jsonStructure, err := entity.MapToJSON(content)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка преобразования JSON: %w", err)
 	}
 
+	bannerVariants, err := buildBannerVariants(variants)
+	if err != nil {
+		return nil, err
+	}
+
 	// Создаем новый баннер
 	newBanner := &entity.Banner{
 		JSONStructure: jsonStructure,
 		FeatureID:     featureID,
 		TagIDs:        tagIDs,
 		IsActive:      isActive,
+		ActiveFrom:    activeFrom,
+		ActiveUntil:   activeUntil,
+		Variants:      bannerVariants,
 	}
 
-	err = uc.BannerRepository.CreateBanner(newBanner)
+	err = uc.BannerRepository.CreateBanner(ctx, newBanner)
 	if err != nil {
 		// Возвращаем ошибку с сообщением об ошибке при создании баннера
-		return nil, fmt.Errorf("ошибка при создании баннера: %w", ErrCreateBanner)
+		return nil, fmt.Errorf("%w: %w", ErrCreateBanner, err)
 	}
 
+	uc.invalidateCache(ctx, newBanner)
+	uc.Audit.Record(ctx, claims, entity.AuditCreate, entity.AuditEntityBanner, newBanner.ID, nil, newBanner)
+
 	return newBanner, nil
 }
 
-// UpdateBanner обновляет информацию о баннере
-func (uc *BannerUseCase) UpdateBanner(id int, tagIDs []int, featureID int, content map[string]interface{}, isActive bool, token string) (*entity.Banner, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return nil, fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+// UpdateBanner обновляет информацию о баннере. Непустой versions обновляет баннер, только если его версия есть в списке
+// и не изменилась до записи, иначе возвращается entity.ErrVersionConflict; versions == nil обновляет баннер без проверки
+func (uc *BannerUseCase) UpdateBanner(ctx context.Context, id int, tagIDs []int, featureID int, content map[string]interface{}, isActive bool, activeFrom, activeUntil *time.Time, variants []entity.BannerVariantRequest, versions []int, token string) (*entity.Banner, error) {
+	// Проверка разрешения токена
+	claims, access, err := uc.authorizeFeatures(ctx, token, entity.PermissionBannerWrite)
+	if err != nil {
+		return nil, err
 	}
 
 	// Проверяем, что tagIDs не пустой и featureID не равен нулю
@@ -116,12 +310,50 @@ func (uc *BannerUseCase) UpdateBanner(id int, tagIDs []int, featureID int, conte
 		return nil, fmt.Errorf("%w", ErrInvalidParams)
 	}
 
+	// Окно показа не может заканчиваться раньше, чем начинается
+	if activeFrom != nil && activeUntil != nil && !activeFrom.Before(*activeUntil) {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
 	// Преобразуем содержимое баннера в формат JSON
 	jsonStructure, err := entity.MapToJSON(content)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка преобразования JSON: %w", err)
 	}
 
+	bannerVariants, err := buildBannerVariants(variants)
+	if err != nil {
+		return nil, err
+	}
+
+	// Доступ к новой фиче проверяется до чтения баннера
+	if !access.allows(featureID) {
+		return nil, authError(auth.ErrPermissionDenied)
+	}
+
+	// Текущее состояние баннера нужно для журнала аудита
+	before, err := uc.BannerRepository.GetBannerByID(ctx, id, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, access.bannerNotFound()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUpdateBanner, err)
+	}
+
+	// Разрешение на фичу нужно и для текущей фичи баннера
+	if !access.allows(before.FeatureID) {
+		return nil, authError(auth.ErrPermissionDenied)
+	}
+
+	// Устаревшую версию видно уже здесь; изменения, сделанные после чтения, отклонит репозиторий
+	version := 0
+	if versions != nil {
+		if !slices.Contains(versions, before.Version) {
+			return nil, fmt.Errorf("%w", entity.ErrVersionConflict)
+		}
+		version = before.Version
+	}
+
 	// Обновляем баннер в репозитории
 	updatedBanner := &entity.Banner{
 		ID:            id,
@@ -129,29 +361,200 @@ func (uc *BannerUseCase) UpdateBanner(id int, tagIDs []int, featureID int, conte
 		FeatureID:     featureID,
 		TagIDs:        tagIDs,
 		IsActive:      isActive,
+		ActiveFrom:    activeFrom,
+		ActiveUntil:   activeUntil,
+		Variants:      bannerVariants,
+		Version:       version,
 	}
 
-	err = uc.BannerRepository.UpdateBanner(updatedBanner)
+	err = uc.BannerRepository.UpdateBanner(ctx, updatedBanner)
+	if errors.Is(err, entity.ErrVersionConflict) {
+		return nil, fmt.Errorf("%w", entity.ErrVersionConflict)
+	}
 	if err != nil {
 		// Возвращаем ошибку с сообщением об ошибке при обновлении баннера
-		return nil, fmt.Errorf("ошибка при обновлении баннера: %w", ErrUpdateBanner)
+		return nil, fmt.Errorf("%w: %w", ErrUpdateBanner, err)
 	}
 
+	uc.invalidateCache(ctx, before, updatedBanner)
+	uc.Audit.Record(ctx, claims, entity.AuditUpdate, entity.AuditEntityBanner, id, before, updatedBanner)
+
 	return updatedBanner, nil
 }
 
 // DeleteBanner удаляет баннер по его ID
-func (uc *BannerUseCase) DeleteBanner(id int, token string) error {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+func (uc *BannerUseCase) DeleteBanner(ctx context.Context, id int, token string) error {
+	// Проверка разрешения токена
+	claims, access, err := uc.authorizeFeatures(ctx, token, entity.PermissionBannerWrite)
+	if err != nil {
+		return err
 	}
 
-	err := uc.BannerRepository.DeleteBannerByID(id)
+	// Текущее состояние баннера нужно для журнала аудита
+	before, err := uc.BannerRepository.GetBannerByID(ctx, id, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return access.bannerNotFound()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDeleteBanner, err)
+	}
+
+	if !access.allows(before.FeatureID) {
+		return authError(auth.ErrPermissionDenied)
+	}
+
+	err = uc.BannerRepository.DeleteBannerByID(ctx, id)
 	if err != nil {
 		// Возвращаем ошибку с сообщением об ошибке при удалении баннера
-		return fmt.Errorf("ошибка при удалении баннера: %w", ErrDeleteBanner)
+		return fmt.Errorf("%w: %w", ErrDeleteBanner, err)
 	}
 
+	uc.invalidateCache(ctx, before)
+	uc.Audit.Record(ctx, claims, entity.AuditDelete, entity.AuditEntityBanner, id, before, nil)
+
 	return nil
 }
+
+// RestoreBanner возвращает удаленный баннер, пока он не удален окончательно
+func (uc *BannerUseCase) RestoreBanner(ctx context.Context, id int, token string) (*entity.Banner, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionBannerWrite)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	err = uc.BannerRepository.RestoreBanner(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w", ErrBannerNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при восстановлении баннера: %w", err)
+	}
+
+	banner, err := uc.BannerRepository.GetBannerByID(ctx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении баннера: %w", err)
+	}
+
+	uc.invalidateCache(ctx, banner)
+	uc.Audit.Record(ctx, claims, entity.AuditRestore, entity.AuditEntityBanner, id, nil, banner)
+
+	return banner, nil
+}
+
+// featureAccess описывает фичи, с баннерами которых пользователю разрешено работать
+type featureAccess struct {
+	all      bool
+	features map[int]bool
+}
+
+// allows сообщает, есть ли доступ ко всем перечисленным фичам
+func (a featureAccess) allows(featureIDs ...int) bool {
+	if a.all {
+		return true
+	}
+	for _, featureID := range featureIDs {
+		if !a.features[featureID] {
+			return false
+		}
+	}
+	return true
+}
+
+// bannerNotFound возвращает ошибку для отсутствующего баннера. Пользователь с доступом только к части фич получает
+// тот же отказ, что и для баннера чужой фичи, чтобы по ответу нельзя было узнать, какие ID баннеров существуют
+func (a featureAccess) bannerNotFound() error {
+	if a.all {
+		return fmt.Errorf("%w", ErrBannerNotFound)
+	}
+	return authError(auth.ErrPermissionDenied)
+}
+
+// authorizeFeatures проверяет разрешение permission: разрешение роли из токена дает доступ ко всем фичам,
+// иначе доступ ограничен фичами из выданных пользователю разрешений. Разрешения на фичи читаются
+// из репозитория при каждом запросе, поэтому их отзыв действует сразу, а не после истечения токена
+func (uc *BannerUseCase) authorizeFeatures(ctx context.Context, token, permission string) (*auth.Claims, featureAccess, error) {
+	claims, err := uc.TokenService.ParseToken(token)
+	if err != nil {
+		return nil, featureAccess{}, authError(err)
+	}
+	if claims.HasPermission(permission) {
+		return claims, featureAccess{all: true}, nil
+	}
+	// Разрешения на фичи выданы пользователю, а не его API ключам: ключ ограничен своими разрешениями
+	if uc.GrantRepository == nil || claims.APIKeyID != 0 {
+		return nil, featureAccess{}, authError(auth.ErrPermissionDenied)
+	}
+
+	grants, err := uc.GrantRepository.GetGrants(ctx, claims.UserID)
+	if err != nil {
+		return nil, featureAccess{}, fmt.Errorf("ошибка при получении разрешений: %w", err)
+	}
+	features := make(map[int]bool)
+	for _, grant := range grants {
+		if grant.Permission == permission {
+			features[grant.FeatureID] = true
+		}
+	}
+	if len(features) == 0 {
+		return nil, featureAccess{}, authError(auth.ErrPermissionDenied)
+	}
+
+	return claims, featureAccess{features: features}, nil
+}
+
+// buildBannerVariants проверяет веса вариантов и преобразует их содержимое в JSON
+func buildBannerVariants(variants []entity.BannerVariantRequest) ([]entity.BannerVariant, error) {
+	bannerVariants := make([]entity.BannerVariant, 0, len(variants))
+	for _, variant := range variants {
+		if variant.Weight <= 0 {
+			return nil, fmt.Errorf("%w", ErrInvalidParams)
+		}
+
+		jsonStructure, err := entity.MapToJSON(variant.Content)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка преобразования JSON: %w", err)
+		}
+
+		bannerVariants = append(bannerVariants, entity.BannerVariant{
+			JSONStructure: jsonStructure,
+			Weight:        variant.Weight,
+		})
+	}
+
+	return bannerVariants, nil
+}
+
+// bannerCursor представляет курсор списка баннеров вместе с сортировкой, для которой он выдан
+type bannerCursor struct {
+	Sort string    `json:"sort"`
+	Desc bool      `json:"desc,omitempty"`
+	ID   int       `json:"id"`
+	At   time.Time `json:"at"`
+}
+
+// encodeBannerCursor кодирует позицию баннера в непрозрачную строку
+func encodeBannerCursor(banner *entity.Banner, sort string, desc bool) string {
+	cursor := bannerCursor{Sort: sort, Desc: desc, ID: banner.ID}
+	if sort != entity.BannerSortID {
+		cursor.At = banner.SortValue(sort)
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeBannerCursor разбирает курсор и проверяет, что он выдан для той же сортировки
+func decodeBannerCursor(value string, sort string, desc bool) (*entity.BannerCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	var cursor bannerCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || cursor.Desc != desc {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	return &entity.BannerCursor{ID: cursor.ID, At: cursor.At}, nil
+}
@@ -1,71 +1,103 @@
 package usecase
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"Avito_task/internal/auth"
-	"Avito_task/internal/db"
 	"Avito_task/internal/entity"
 )
 
+// ErrFeatureNotFound возвращается при изменении или удалении несуществующей фичи
+var ErrFeatureNotFound = errors.New("фича не найдена")
+
 // FeatureUseCase представляет интерфейс для работы с фичами
 type FeatureUseCase struct {
-	FeatureRepository *db.FeatureRepository
+	FeatureRepository FeatureRepository
 	TokenService      *auth.TokenService
+	Audit             *AuditUseCase
 }
 
-// NewFeatureUseCase создает новый экземпляр FeatureUseCase
-func NewFeatureUseCase(featureRepo *db.FeatureRepository, tokenService *auth.TokenService) *FeatureUseCase {
+// NewFeatureUseCase создает новый экземпляр FeatureUseCase; при audit == nil изменения не попадают в журнал аудита
+func NewFeatureUseCase(featureRepo FeatureRepository, tokenService *auth.TokenService, audit *AuditUseCase) *FeatureUseCase {
 	return &FeatureUseCase{
 		FeatureRepository: featureRepo,
 		TokenService:      tokenService,
+		Audit:             audit,
 	}
 }
 
 // CreateFeature создает новую фичу
-func (uc *FeatureUseCase) CreateFeature(name string, token string) (*entity.Feature, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return nil, fmt.Errorf("ошибка авторизации: %w", err)
+func (uc *FeatureUseCase) CreateFeature(ctx context.Context, name string, token string) (*entity.Feature, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionCatalogWrite)
+	if err != nil {
+		return nil, authError(err)
 	}
 
 	newFeature := &entity.Feature{Name: name}
-	if err := uc.FeatureRepository.CreateFeature(newFeature); err != nil {
+	if err := uc.FeatureRepository.CreateFeature(ctx, newFeature); err != nil {
 		return nil, fmt.Errorf("ошибка при создании новой фичи: %w", err)
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditCreate, entity.AuditEntityFeature, newFeature.ID, nil, newFeature)
+
 	return newFeature, nil
 }
 
 // UpdateFeature обновляет информацию о фиче
-func (uc *FeatureUseCase) UpdateFeature(id int, newName string, token string) (*entity.Feature, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return nil, fmt.Errorf("ошибка авторизации: %w", err)
+func (uc *FeatureUseCase) UpdateFeature(ctx context.Context, id int, newName string, token string) (*entity.Feature, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionCatalogWrite)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	before, err := uc.FeatureRepository.GetFeatureByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w", ErrFeatureNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении фичи: %w", err)
 	}
 
-	if err := uc.FeatureRepository.UpdateFeature(id, newName); err != nil {
+	if err := uc.FeatureRepository.UpdateFeature(ctx, id, newName); err != nil {
 		return nil, fmt.Errorf("ошибка при обновлении информации о фичи: %w", err)
 	}
 
-	feature, err := uc.FeatureRepository.GetFeatureByID(id)
+	feature, err := uc.FeatureRepository.GetFeatureByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при получении фичи: %w", err)
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditUpdate, entity.AuditEntityFeature, id, before, feature)
+
 	return feature, nil
 }
 
 // DeleteFeature удаляет фичу по ID
-func (uc *FeatureUseCase) DeleteFeature(id int, token string) error {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return fmt.Errorf("ошибка авторизации: %w", err)
+func (uc *FeatureUseCase) DeleteFeature(ctx context.Context, id int, token string) error {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionCatalogWrite)
+	if err != nil {
+		return authError(err)
+	}
+
+	before, err := uc.FeatureRepository.GetFeatureByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w", ErrFeatureNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при получении фичи: %w", err)
 	}
 
-	if err := uc.FeatureRepository.DeleteFeatureByID(id); err != nil {
+	if err := uc.FeatureRepository.DeleteFeatureByID(ctx, id); err != nil {
 		return fmt.Errorf("ошибка при удалении фичи: %w", err)
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditDelete, entity.AuditEntityFeature, id, before, nil)
+
 	return nil
 }
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"Avito_task/internal/entity"
+)
+
+// Интерфейсы хранилищ, от которых зависят сценарии использования.
+// Реализации, не находящие запись, возвращают sql.ErrNoRows.
+
+// BannerRepository описывает хранилище баннеров
+type BannerRepository interface {
+	CreateBanner(ctx context.Context, banner *entity.Banner) error
+	GetBannerByID(ctx context.Context, id int, useLastRevision bool) (*entity.Banner, error)
+	GetUserBanner(ctx context.Context, tagID, featureID int, now time.Time) (*entity.Banner, error)
+	// GetBannerByFeatureAndTags возвращает неудаленный баннер фичи с тем же набором тегов, что и tagIDs
+	GetBannerByFeatureAndTags(ctx context.Context, featureID int, tagIDs []int) (*entity.Banner, error)
+	GetScheduledBanners(ctx context.Context, from, to time.Time) ([]*entity.Banner, error)
+	// GetSnapshotBanners возвращает все неудаленные баннеры с тегами и вариантами, согласованные на один момент
+	GetSnapshotBanners(ctx context.Context) ([]*entity.Banner, error)
+	// UpdateBanner увеличивает версию баннера; при ненулевой banner.Version, отличной от сохраненной, возвращает entity.ErrVersionConflict
+	UpdateBanner(ctx context.Context, banner *entity.Banner) error
+	DeleteBannerByID(ctx context.Context, id int) error
+	RestoreBanner(ctx context.Context, id int) error
+	PurgeDeletedBanners(ctx context.Context, before time.Time) (int, error)
+	GetAllBanners(ctx context.Context, query entity.BannerListQuery) ([]*entity.Banner, error)
+	CountBanners(ctx context.Context, filter entity.BannerFilter) (int, error)
+	// InTransaction выполняет fn так, что изменения, сделанные вызовами репозитория с контекстом fn,
+	// применяются или откатываются вместе
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TagRepository описывает хранилище тегов
+type TagRepository interface {
+	CreateTag(ctx context.Context, tag *entity.Tag) error
+	GetTagByID(ctx context.Context, id int) (*entity.Tag, error)
+	UpdateTag(ctx context.Context, id int, newName string) error
+	DeleteTagByID(ctx context.Context, id int) error
+}
+
+// FeatureRepository описывает хранилище фич
+type FeatureRepository interface {
+	CreateFeature(ctx context.Context, feature *entity.Feature) error
+	GetFeatureByID(ctx context.Context, id int) (*entity.Feature, error)
+	UpdateFeature(ctx context.Context, id int, newName string) error
+	DeleteFeatureByID(ctx context.Context, id int) error
+}
+
+// UserRepository описывает хранилище пользователей
+type UserRepository interface {
+	// CreateUser возвращает entity.ErrUsernameTaken, если имя уже занято
+	CreateUser(ctx context.Context, user *entity.User) error
+	GetUserByID(ctx context.Context, id int) (*entity.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*entity.User, error)
+	// UpdateUser возвращает entity.ErrUsernameTaken, если новое имя занято другим пользователем
+	UpdateUser(ctx context.Context, user *entity.User) error
+	DeleteUserByID(ctx context.Context, id int) error
+	IncrementFailedLogins(ctx context.Context, id int) (int, error)
+	LockUser(ctx context.Context, id int, until time.Time) error
+	ResetFailedLogins(ctx context.Context, id int) error
+}
+
+// RoleRepository описывает хранилище ролей и их разрешений
+type RoleRepository interface {
+	GetRole(ctx context.Context, name string) (*entity.Role, error)
+	GetRoles(ctx context.Context) ([]*entity.Role, error)
+	SaveRole(ctx context.Context, role *entity.Role) error
+}
+
+// GrantRepository описывает хранилище разрешений, выданных пользователям на отдельные фичи
+type GrantRepository interface {
+	CreateGrant(ctx context.Context, grant *entity.PermissionGrant) error
+	GetGrants(ctx context.Context, userID int) ([]*entity.PermissionGrant, error)
+	DeleteGrant(ctx context.Context, id int) (*entity.PermissionGrant, error)
+}
+
+// APIKeyRepository описывает хранилище API ключей; ключи ищутся по хешу, а не по значению
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *entity.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int) (*entity.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error
+}
+
+// StatsRepository описывает хранилище статистики показов и кликов
+type StatsRepository interface {
+	IncrementBannerStats(ctx context.Context, stats []*entity.BannerStat) error
+	GetBannerStats(ctx context.Context, bannerID int, from, to time.Time) ([]*entity.BannerStat, error)
+}
+
+// AuditRepository описывает журнал аудита; записи в нем только добавляются
+type AuditRepository interface {
+	CreateAuditRecord(ctx context.Context, record *entity.AuditRecord) error
+	GetAuditRecords(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditRecord, error)
+}
@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"Avito_task/internal/auth"
+	"Avito_task/internal/entity"
+)
+
+// statsKey идентифицирует счетчик статистики в буфере
+type statsKey struct {
+	day      time.Time
+	bannerID int
+	tagID    int
+}
+
+// StatsUseCase накапливает показы и клики баннеров в памяти и пачками сохраняет их в базу данных
+type StatsUseCase struct {
+	StatsRepository  StatsRepository
+	BannerRepository BannerRepository
+	TokenService     *auth.TokenService
+	FlushInterval    time.Duration
+	BatchSize        int
+
+	mu      sync.Mutex
+	buffer  map[statsKey]*entity.BannerStat
+	pending int
+	flushCh chan struct{}
+}
+
+// NewStatsUseCase создает новый экземпляр StatsUseCase
+func NewStatsUseCase(statsRepo StatsRepository, bannerRepo BannerRepository, tokenService *auth.TokenService, flushInterval time.Duration, batchSize int) *StatsUseCase {
+	return &StatsUseCase{
+		StatsRepository:  statsRepo,
+		BannerRepository: bannerRepo,
+		TokenService:     tokenService,
+		FlushInterval:    flushInterval,
+		BatchSize:        batchSize,
+		buffer:           make(map[statsKey]*entity.BannerStat),
+		flushCh:          make(chan struct{}, 1),
+	}
+}
+
+// RecordImpression учитывает показ баннера пользователю по тегу
+func (uc *StatsUseCase) RecordImpression(banner *entity.Banner, tagID int) {
+	uc.record(banner.ID, tagID, banner.FeatureID, 1, 0)
+}
+
+// RecordClick учитывает клик пользователя по баннеру, показанному по тегу tagID; тег должен быть тегом баннера
+func (uc *StatsUseCase) RecordClick(ctx context.Context, bannerID, tagID int, token string) error {
+	// Проверка токена пользователя
+	if _, err := uc.TokenService.ParseToken(token); err != nil {
+		return fmt.Errorf("ошибка авторизации: %w", ErrUnauthorized)
+	}
+
+	// Без тега клик нельзя сопоставить с показом
+	if tagID <= 0 {
+		return fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	banner, err := uc.BannerRepository.GetBannerByID(ctx, bannerID, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w", ErrBannerNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при получении баннера: %w", err)
+	}
+
+	// Баннер не показывается по чужому тегу, поэтому клик по нему только исказил бы статистику
+	if !slices.Contains(banner.TagIDs, tagID) {
+		return fmt.Errorf("%w: тег %d не относится к баннеру", ErrInvalidParams, tagID)
+	}
+
+	uc.record(banner.ID, tagID, banner.FeatureID, 0, 1)
+	return nil
+}
+
+// GetBannerStats возвращает дневную статистику баннера за период [from, to], сгруппированную по groupBy:
+// entity.StatsGroupByTag, entity.StatsGroupByFeature или entity.StatsGroupByBanner
+func (uc *StatsUseCase) GetBannerStats(ctx context.Context, bannerID int, from, to time.Time, groupBy string, token string) ([]*entity.BannerStat, error) {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionBannerRead); err != nil {
+		return nil, authError(err)
+	}
+
+	if from.After(to) {
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+	switch groupBy {
+	case entity.StatsGroupByTag, entity.StatsGroupByFeature, entity.StatsGroupByBanner:
+	default:
+		return nil, fmt.Errorf("%w", ErrInvalidParams)
+	}
+
+	stats, err := uc.StatsRepository.GetBannerStats(ctx, bannerID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении статистики: %w", err)
+	}
+
+	if groupBy == entity.StatsGroupByTag {
+		return stats, nil
+	}
+	return groupBannerStats(stats, groupBy), nil
+}
+
+// groupBannerStats суммирует дневную статистику по тегам баннера, сохраняя порядок дней;
+// для entity.StatsGroupByFeature строки разных фич остаются отдельными
+func groupBannerStats(stats []*entity.BannerStat, groupBy string) []*entity.BannerStat {
+	type groupKey struct {
+		day       time.Time
+		featureID int
+	}
+
+	grouped := make([]*entity.BannerStat, 0, len(stats))
+	index := make(map[groupKey]*entity.BannerStat)
+	for _, stat := range stats {
+		key := groupKey{day: stat.Date}
+		if groupBy == entity.StatsGroupByFeature {
+			key.featureID = stat.FeatureID
+		}
+
+		group, ok := index[key]
+		if !ok {
+			group = &entity.BannerStat{Date: stat.Date, BannerID: stat.BannerID, FeatureID: key.featureID}
+			index[key] = group
+			grouped = append(grouped, group)
+		}
+		group.Impressions += stat.Impressions
+		group.Clicks += stat.Clicks
+	}
+
+	for _, group := range grouped {
+		group.CalculateCTR()
+	}
+	sort.SliceStable(grouped, func(i, j int) bool {
+		if !grouped[i].Date.Equal(grouped[j].Date) {
+			return grouped[i].Date.Before(grouped[j].Date)
+		}
+		return grouped[i].FeatureID < grouped[j].FeatureID
+	})
+
+	return grouped
+}
+
+// Run сохраняет накопленную статистику по таймеру или при заполнении буфера до отмены контекста
+func (uc *StatsUseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(uc.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Контекст уже отменен, поэтому последняя порция сохраняется без него
+			if err := uc.Flush(context.Background()); err != nil {
+				slog.Error("ошибка при сохранении статистики", "error", err)
+			}
+			return
+		case <-ticker.C:
+		case <-uc.flushCh:
+		}
+
+		if err := uc.Flush(ctx); err != nil {
+			slog.Error("ошибка при сохранении статистики", "error", err)
+		}
+	}
+}
+
+// Flush сохраняет накопленные счетчики; при ошибке они возвращаются в буфер
+func (uc *StatsUseCase) Flush(ctx context.Context) error {
+	uc.mu.Lock()
+	buffer := uc.buffer
+	uc.buffer = make(map[statsKey]*entity.BannerStat)
+	uc.pending = 0
+	uc.mu.Unlock()
+
+	if len(buffer) == 0 {
+		return nil
+	}
+
+	stats := make([]*entity.BannerStat, 0, len(buffer))
+	for _, stat := range buffer {
+		stats = append(stats, stat)
+	}
+
+	if err := uc.StatsRepository.IncrementBannerStats(ctx, stats); err != nil {
+		uc.mu.Lock()
+		for _, stat := range stats {
+			uc.merge(stat)
+		}
+		uc.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// record добавляет события в буфер и будит фоновый процесс при заполнении пачки
+func (uc *StatsUseCase) record(bannerID, tagID, featureID int, impressions, clicks int64) {
+	now := time.Now().UTC()
+	stat := &entity.BannerStat{
+		Date:        time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		BannerID:    bannerID,
+		TagID:       tagID,
+		FeatureID:   featureID,
+		Impressions: impressions,
+		Clicks:      clicks,
+	}
+
+	uc.mu.Lock()
+	uc.merge(stat)
+	uc.pending++
+	full := uc.pending >= uc.BatchSize
+	uc.mu.Unlock()
+
+	if full {
+		select {
+		case uc.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// merge прибавляет счетчики к буферу; вызывается под блокировкой
+func (uc *StatsUseCase) merge(stat *entity.BannerStat) {
+	key := statsKey{day: stat.Date, bannerID: stat.BannerID, tagID: stat.TagID}
+	if existing, ok := uc.buffer[key]; ok {
+		existing.Impressions += stat.Impressions
+		existing.Clicks += stat.Clicks
+		existing.FeatureID = stat.FeatureID
+		return
+	}
+	uc.buffer[key] = stat
+}
@@ -2,72 +2,100 @@
 package usecase
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"Avito_task/internal/auth"
-	"Avito_task/internal/db"
 	"Avito_task/internal/entity"
 )
 
+// ErrTagNotFound возвращается при изменении или удалении несуществующего тега
+var ErrTagNotFound = errors.New("тег не найден")
+
 // TagUseCase представляет интерфейс для работы с тегами
 type TagUseCase struct {
-	TagRepository db.TagRepository
+	TagRepository TagRepository
 	TokenService  *auth.TokenService
+	Audit         *AuditUseCase
 }
 
-// NewTagUseCase создает новый экземпляр TagUseCase
-func NewTagUseCase(tagRepo db.TagRepository, tokenService auth.TokenService) *TagUseCase {
+// NewTagUseCase создает новый экземпляр TagUseCase; при audit == nil изменения не попадают в журнал аудита
+func NewTagUseCase(tagRepo TagRepository, tokenService *auth.TokenService, audit *AuditUseCase) *TagUseCase {
 	return &TagUseCase{
 		TagRepository: tagRepo,
-		TokenService:  &tokenService,
+		TokenService:  tokenService,
+		Audit:         audit,
 	}
 }
 
 // CreateTag создает новый тег
-func (uc *TagUseCase) CreateTag(name string, token string) (*entity.Tag, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return nil, fmt.Errorf("ошибка авторизации: %w", err)
+func (uc *TagUseCase) CreateTag(ctx context.Context, name string, token string) (*entity.Tag, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionCatalogWrite)
+	if err != nil {
+		return nil, authError(err)
 	}
 
 	newTag := &entity.Tag{Name: name}
-	if err := uc.TagRepository.CreateTag(newTag); err != nil {
+	if err := uc.TagRepository.CreateTag(ctx, newTag); err != nil {
 		return nil, fmt.Errorf("ошибка при создании нового тега: %w", err)
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditCreate, entity.AuditEntityTag, newTag.ID, nil, newTag)
+
 	return newTag, nil
 }
 
 // UpdateTag обновляет информацию о теге
-func (uc *TagUseCase) UpdateTag(id int, newName string, token string) (*entity.Tag, error) {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return nil, fmt.Errorf("ошибка авторизации: %w", err)
+func (uc *TagUseCase) UpdateTag(ctx context.Context, id int, newName string, token string) (*entity.Tag, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionCatalogWrite)
+	if err != nil {
+		return nil, authError(err)
 	}
 
-	tag, err := uc.TagRepository.GetTagByID(id)
+	tag, err := uc.TagRepository.GetTagByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w", ErrTagNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при получении тега: %w", err)
 	}
 
+	before := *tag
 	tag.Name = newName
-	if err := uc.TagRepository.UpdateTag(id, newName); err != nil {
+	if err := uc.TagRepository.UpdateTag(ctx, id, newName); err != nil {
 		return nil, fmt.Errorf("ошибка при обновлении информации о теге: %w", err)
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditUpdate, entity.AuditEntityTag, id, before, tag)
+
 	return tag, nil
 }
 
 // DeleteTag удаляет тег по ID
-func (uc *TagUseCase) DeleteTag(id int, token string) error {
-	// Проверка токена администратора
-	if err := uc.TokenService.VerifyAdminToken(token); err != nil {
-		return fmt.Errorf("ошибка авторизации: %w", err)
+func (uc *TagUseCase) DeleteTag(ctx context.Context, id int, token string) error {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionCatalogWrite)
+	if err != nil {
+		return authError(err)
+	}
+
+	before, err := uc.TagRepository.GetTagByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w", ErrTagNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при получении тега: %w", err)
 	}
 
-	if err := uc.TagRepository.DeleteTagByID(id); err != nil {
+	if err := uc.TagRepository.DeleteTagByID(ctx, id); err != nil {
 		return fmt.Errorf("ошибка при удалении тега: %w", err)
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditDelete, entity.AuditEntityTag, id, before, nil)
+
 	return nil
 }
@@ -1,26 +1,202 @@
 package usecase
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"Avito_task/internal/auth"
-	"Avito_task/internal/db"
 	"Avito_task/internal/entity"
+	"Avito_task/internal/logging"
 )
 
+var (
+	// ErrInvalidCredentials возвращается при неизвестном имени пользователя или неверном пароле;
+	// причины не различаются, чтобы по ответу нельзя было подобрать существующие имена
+	ErrInvalidCredentials = errors.New("неверное имя пользователя или пароль")
+	// ErrAccountLocked возвращается, пока вход заблокирован после неудачных попыток
+	ErrAccountLocked = errors.New("вход временно заблокирован")
+	// ErrGrantNotFound возвращается при отзыве несуществующего разрешения на фичу
+	ErrGrantNotFound = errors.New("разрешение не найдено")
+)
+
+// dummyPasswordHash является bcrypt хешем со стоимостью bcrypt.DefaultCost, с которым сравнивается пароль
+// неизвестного пользователя, чтобы по времени ответа нельзя было отличить его от существующего
+const dummyPasswordHash = "$2a$10$fqambW4CG64ijqgQchZfaOdlDjOW21HyQ5jeO.sNier9eoL9qO7TK"
+
+// maxTrackedUnknownLogins ограничивает число несуществующих имен, неудачные входы под которыми учитываются в памяти
+const maxTrackedUnknownLogins = 10000
+
+// AccountLockedError сообщает, до какого момента заблокирован вход; errors.Is(err, ErrAccountLocked) для нее истинно
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("%s до %s", ErrAccountLocked, e.Until.Format(time.RFC3339))
+}
+
+func (e *AccountLockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// UserPolicy задает правила паролей, блокировки входа и допустимые роли
+type UserPolicy struct {
+	// MinPasswordLength задает минимальную длину пароля в символах
+	MinPasswordLength int
+	// MaxFailedLogins задает число неудачных попыток подряд, после которого вход блокируется; 0 отключает блокировку
+	MaxFailedLogins int
+	// LockoutBase задает длительность первой блокировки; каждая следующая неудачная попытка удваивает ее
+	LockoutBase time.Duration
+	// LockoutMax ограничивает длительность блокировки
+	LockoutMax time.Duration
+}
+
+// DefaultUserPolicy возвращает правила, которые действуют, если в конфигурации не задано иное
+func DefaultUserPolicy() UserPolicy {
+	return UserPolicy{
+		MinPasswordLength: 8,
+		MaxFailedLogins:   5,
+		LockoutBase:       time.Minute,
+		LockoutMax:        time.Hour,
+	}
+}
+
+// validatePassword проверяет пароль: он не короче MinPasswordLength, содержит буквы и цифры и не совпадает с именем
+func (p UserPolicy) validatePassword(username, password string) error {
+	if utf8.RuneCountInString(password) < p.MinPasswordLength {
+		return fmt.Errorf("%w: пароль короче %d символов", ErrInvalidParams, p.MinPasswordLength)
+	}
+
+	hasLetter, hasDigit := false, false
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("%w: пароль должен содержать буквы и цифры", ErrInvalidParams)
+	}
+
+	if strings.EqualFold(password, username) {
+		return fmt.Errorf("%w: пароль не должен совпадать с именем пользователя", ErrInvalidParams)
+	}
+
+	return nil
+}
+
+// lockout возвращает длительность блокировки после failures неудачных попыток подряд или 0, если блокировать рано
+func (p UserPolicy) lockout(failures int) time.Duration {
+	if p.MaxFailedLogins <= 0 || failures < p.MaxFailedLogins {
+		return 0
+	}
+
+	duration := p.LockoutBase
+	for i := p.MaxFailedLogins; i < failures && duration < p.LockoutMax; i++ {
+		duration *= 2
+	}
+	if p.LockoutMax > 0 && duration > p.LockoutMax {
+		duration = p.LockoutMax
+	}
+	return duration
+}
+
 // UserUseCase представляет интерфейс для работы с пользователями
 type UserUseCase struct {
-	UserRepository db.UserRepository
-	TokenService   *auth.TokenService
+	UserRepository  UserRepository
+	RoleRepository  RoleRepository
+	GrantRepository GrantRepository
+	TokenService    *auth.TokenService
+	Policy          UserPolicy
+	Audit           *AuditUseCase
+
+	unknownLogins unknownLogins
+}
+
+// unknownLogins учитывает неудачные входы под несуществующими именами по тем же правилам, что и для пользователей,
+// чтобы по блокировке нельзя было узнать, какие имена существуют. Счетчики хранятся в памяти реплики
+type unknownLogins struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+// loginFailures содержит неудачные попытки входа под одним несуществующим именем
+type loginFailures struct {
+	count       int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// lockedUntil возвращает момент окончания блокировки имени или нулевое время, если вход не заблокирован
+func (l *unknownLogins) lockedUntil(username string, now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if f, ok := l.failures[username]; ok && now.Before(f.lockedUntil) {
+		return f.lockedUntil
+	}
+	return time.Time{}
+}
+
+// fail учитывает неудачную попытку и возвращает число попыток подряд. Если отслеживаемых имен слишком много,
+// сначала забываются имена без действующей блокировки, неудачи которых старше policy.LockoutMax
+func (l *unknownLogins) fail(username string, now time.Time, policy UserPolicy) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.failures == nil {
+		l.failures = make(map[string]*loginFailures)
+	}
+	f, ok := l.failures[username]
+	if !ok {
+		if len(l.failures) >= maxTrackedUnknownLogins {
+			for name, old := range l.failures {
+				if !now.Before(old.lockedUntil) && now.Sub(old.lastFailure) > policy.LockoutMax {
+					delete(l.failures, name)
+				}
+			}
+		}
+		if len(l.failures) >= maxTrackedUnknownLogins {
+			return 1
+		}
+		f = &loginFailures{}
+		l.failures[username] = f
+	}
+
+	f.count++
+	f.lastFailure = now
+	if duration := policy.lockout(f.count); duration > 0 {
+		f.lockedUntil = now.Add(duration)
+	}
+	return f.count
 }
 
-// NewUserUseCase создает новый экземпляр UserUseCase
-func NewUserUseCase(userRepo db.UserRepository, tokenService *auth.TokenService) *UserUseCase {
+// userAuditView представляет пользователя в журнале аудита: хеш пароля не пишется, фиксируется только факт его смены
+type userAuditView struct {
+	*entity.User
+	PasswordChanged bool `json:"password_changed,omitempty"`
+}
+
+// NewUserUseCase создает новый экземпляр UserUseCase; при audit == nil изменения не попадают в журнал аудита
+func NewUserUseCase(userRepo UserRepository, roleRepo RoleRepository, grantRepo GrantRepository, tokenService *auth.TokenService, policy UserPolicy, audit *AuditUseCase) *UserUseCase {
 	return &UserUseCase{
-		UserRepository: userRepo,
-		TokenService:   tokenService,
+		UserRepository:  userRepo,
+		RoleRepository:  roleRepo,
+		GrantRepository: grantRepo,
+		TokenService:    tokenService,
+		Policy:          policy,
+		Audit:           audit,
 	}
 }
 
@@ -39,8 +215,37 @@ func (uc *UserUseCase) CheckPasswordHash(password, hashedPassword string) bool {
 	return err == nil
 }
 
-// RegisterUser регистрирует нового пользователя
-func (uc *UserUseCase) RegisterUser(username, password, role string) (*entity.User, error) {
+// RegisterUser регистрирует нового пользователя. Пустая роль означает обычного пользователя;
+// любую другую роль из хранилища ролей может выдать только владелец токена с разрешением user:manage,
+// у которого есть все разрешения этой роли
+func (uc *UserUseCase) RegisterUser(ctx context.Context, username, password, role string, token string) (*entity.User, error) {
+	if username == "" {
+		return nil, fmt.Errorf("%w: пустое имя пользователя", ErrInvalidParams)
+	}
+	if role == "" {
+		role = entity.RoleUser
+	}
+	stored, err := uc.getRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.Policy.validatePassword(username, password); err != nil {
+		return nil, err
+	}
+
+	// Пользователь регистрируется сам, поэтому он же считается автором записи, если роль не выдает другой пользователь
+	var actor *auth.Claims
+	if role != entity.RoleUser {
+		claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+		if err != nil {
+			return nil, authError(err)
+		}
+		if err := canAssignRole(claims, stored); err != nil {
+			return nil, err
+		}
+		actor = claims
+	}
+
 	// Hash the password before storing it
 	hashedPassword, err := uc.HashPassword(password)
 	if err != nil {
@@ -54,32 +259,110 @@ func (uc *UserUseCase) RegisterUser(username, password, role string) (*entity.Us
 	}
 
 	// Save the user to the database
-	err = uc.UserRepository.CreateUser(newUser)
+	err = uc.UserRepository.CreateUser(ctx, newUser)
 	if err != nil {
 		return nil, err
 	}
 
+	if actor == nil {
+		actor = &auth.Claims{UserID: newUser.ID}
+	}
+	uc.Audit.Record(ctx, actor, entity.AuditCreate, entity.AuditEntityUser, newUser.ID, nil, userAuditView{User: newUser})
+
 	return newUser, nil
 }
 
-// AuthenticateUser аутентифицирует пользователя (логин)
-func (uc *UserUseCase) AuthenticateUser(username, password string) (*entity.User, error) {
-	user, err := uc.UserRepository.GetUserByUsername(username)
+// AuthenticateUser аутентифицирует пользователя (логин).
+// После Policy.MaxFailedLogins неудачных попыток подряд вход блокируется, и каждая следующая неудача удваивает блокировку;
+// во время блокировки пароль не проверяется, чтобы его нельзя было подбирать дальше
+func (uc *UserUseCase) AuthenticateUser(ctx context.Context, username, password string) (*entity.User, error) {
+	now := time.Now()
+	user, err := uc.UserRepository.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, uc.failUnknownLogin(username, password, now)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return nil, &AccountLockedError{Until: *user.LockedUntil}
+	}
+
 	// Check if the password matches the stored hash
 	if !uc.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, fmt.Errorf("неправильный пароль")
+		failures, err := uc.UserRepository.IncrementFailedLogins(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при учете неудачного входа: %w", err)
+		}
+		if duration := uc.Policy.lockout(failures); duration > 0 {
+			until := now.Add(duration)
+			if err := uc.UserRepository.LockUser(ctx, user.ID, until); err != nil {
+				return nil, fmt.Errorf("ошибка при блокировке входа: %w", err)
+			}
+			logging.FromContext(ctx).Warn("вход пользователя заблокирован",
+				"user_id", user.ID,
+				"failed_logins", failures,
+				"until", until,
+			)
+			return nil, &AccountLockedError{Until: until}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := uc.UserRepository.ResetFailedLogins(ctx, user.ID); err != nil {
+			return nil, fmt.Errorf("ошибка при сбросе неудачных входов: %w", err)
+		}
 	}
 
 	return user, nil
 }
 
+// failUnknownLogin отвечает на вход под несуществующим именем так же, как на неверный пароль существующего пользователя:
+// пароль сравнивается с фиктивным хешем, а неудачи подряд блокируют вход под этим именем
+func (uc *UserUseCase) failUnknownLogin(username, password string, now time.Time) error {
+	if until := uc.unknownLogins.lockedUntil(username, now); !until.IsZero() {
+		return &AccountLockedError{Until: until}
+	}
+
+	uc.CheckPasswordHash(password, dummyPasswordHash)
+	failures := uc.unknownLogins.fail(username, now, uc.Policy)
+	if duration := uc.Policy.lockout(failures); duration > 0 {
+		return &AccountLockedError{Until: now.Add(duration)}
+	}
+	return ErrInvalidCredentials
+}
+
+// Login аутентифицирует пользователя и выдает ему токен с разрешениями его роли.
+// Изменения роли применяются к токенам, выданным после них
+func (uc *UserUseCase) Login(ctx context.Context, username, password string) (string, error) {
+	user, err := uc.AuthenticateUser(ctx, username, password)
+	if err != nil {
+		return "", err
+	}
+
+	// Удаленная из хранилища роль не дает разрешений
+	permissions := []string{}
+	role, err := uc.RoleRepository.GetRole(ctx, user.Role)
+	switch {
+	case err == nil:
+		permissions = role.Permissions
+	case !errors.Is(err, sql.ErrNoRows):
+		return "", fmt.Errorf("ошибка при получении роли: %w", err)
+	}
+
+	token, err := uc.TokenService.GenerateToken(user.ID, user.Role, permissions)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при выдаче токена: %w", err)
+	}
+
+	return token, nil
+}
+
 // GetUserByID получает информацию о пользователе по его ID
-func (uc *UserUseCase) GetUserByID(id int) (*entity.User, error) {
-	user, err := uc.UserRepository.GetUserByID(id)
+func (uc *UserUseCase) GetUserByID(ctx context.Context, id int) (*entity.User, error) {
+	user, err := uc.UserRepository.GetUserByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -87,19 +370,51 @@ func (uc *UserUseCase) GetUserByID(id int) (*entity.User, error) {
 	return user, nil
 }
 
-// UpdateUser обновляет информацию о пользователе
-func (uc *UserUseCase) UpdateUser(id int, username, password, role string) (*entity.User, error) {
+// UpdateUser обновляет информацию о пользователе; требует разрешения user:manage и всех разрешений
+// прежней и новой роли пользователя
+func (uc *UserUseCase) UpdateUser(ctx context.Context, id int, username, password, role string, token string) (*entity.User, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return nil, authError(err)
+	}
+
 	// Получите пользователя из базы данных
-	user, err := uc.UserRepository.GetUserByID(id)
+	user, err := uc.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *user
+
+	if username == "" {
+		return nil, fmt.Errorf("%w: пустое имя пользователя", ErrInvalidParams)
+	}
+	stored, err := uc.getRole(ctx, role)
 	if err != nil {
 		return nil, err
 	}
+	if err := canAssignRole(claims, stored); err != nil {
+		return nil, err
+	}
+	// Пользователя с более широкой ролью менять нельзя: сменой пароля можно было бы войти под ним
+	current, err := uc.RoleRepository.GetRole(ctx, before.Role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ошибка при получении роли: %w", err)
+	}
+	if current != nil {
+		if err := canAssignRole(claims, current); err != nil {
+			return nil, err
+		}
+	}
 
 	// Обновите данные пользователя
 	user.Username = username
 	user.Role = role
 	// Хешируйте новый пароль, если он был предоставлен
 	if password != "" {
+		if err := uc.Policy.validatePassword(username, password); err != nil {
+			return nil, err
+		}
 		hashedPassword, err := uc.HashPassword(password)
 		if err != nil {
 			return nil, err
@@ -108,20 +423,191 @@ func (uc *UserUseCase) UpdateUser(id int, username, password, role string) (*ent
 	}
 
 	// Вызовите метод UpdateUser из UserRepository
-	err = uc.UserRepository.UpdateUser(user)
+	err = uc.UserRepository.UpdateUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditUpdate, entity.AuditEntityUser, id,
+		userAuditView{User: &before}, userAuditView{User: user, PasswordChanged: password != ""})
+
 	return user, nil
 }
 
-// DeleteUserByID удаляет пользователя по его ID
-func (uc *UserUseCase) DeleteUserByID(id int) error {
-	err := uc.UserRepository.DeleteUserByID(id)
+// DeleteUserByID удаляет пользователя по его ID; требует разрешения user:manage
+func (uc *UserUseCase) DeleteUserByID(ctx context.Context, id int, token string) error {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return authError(err)
+	}
+
+	before, err := uc.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = uc.UserRepository.DeleteUserByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	uc.Audit.Record(ctx, claims, entity.AuditDelete, entity.AuditEntityUser, id, userAuditView{User: before}, nil)
+
+	return nil
+}
+
+// GetRoles возвращает все роли с их разрешениями; требует разрешения user:manage
+func (uc *UserUseCase) GetRoles(ctx context.Context, token string) ([]*entity.Role, error) {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionUserManage); err != nil {
+		return nil, authError(err)
+	}
+
+	roles, err := uc.RoleRepository.GetRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ролей: %w", err)
+	}
+
+	return roles, nil
+}
+
+// SaveRole создает роль или заменяет ее разрешения; требует разрешения user:manage и всех разрешений роли.
+// Роль администратора не меняется, чтобы нельзя было лишить всех права управлять ролями
+func (uc *UserUseCase) SaveRole(ctx context.Context, role *entity.Role, token string) (*entity.Role, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	if role.Name == "" || role.Name == entity.RoleAdmin {
+		return nil, fmt.Errorf("%w: недопустимое имя роли %q", ErrInvalidParams, role.Name)
+	}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	for _, permission := range role.Permissions {
+		if !entity.IsPermission(permission) {
+			return nil, fmt.Errorf("%w: неизвестное разрешение %q", ErrInvalidParams, permission)
+		}
+	}
+	if err := canAssignRole(claims, role); err != nil {
+		return nil, err
+	}
+
+	before, err := uc.RoleRepository.GetRole(ctx, role.Name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("ошибка при получении роли: %w", err)
+	}
+
+	if err := uc.RoleRepository.SaveRole(ctx, role); err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении роли: %w", err)
+	}
+
+	// У ролей нет числового ID, поэтому в журнале они различаются по имени в содержимом записи
+	if before == nil {
+		uc.Audit.Record(ctx, claims, entity.AuditCreate, entity.AuditEntityRole, 0, nil, role)
+	} else {
+		uc.Audit.Record(ctx, claims, entity.AuditUpdate, entity.AuditEntityRole, 0, before, role)
+	}
+
+	return role, nil
+}
+
+// canAssignRole проверяет, что владелец токена может выдать роль: роль администратора выдает только администратор,
+// а остальные роли - только тот, у кого есть все их разрешения
+func canAssignRole(claims *auth.Claims, role *entity.Role) error {
+	if role.Name == entity.RoleAdmin && claims.Role != entity.RoleAdmin {
+		return authError(auth.ErrPermissionDenied)
+	}
+	for _, permission := range role.Permissions {
+		if !claims.HasPermission(permission) {
+			return authError(auth.ErrPermissionDenied)
+		}
+	}
+	return nil
+}
+
+// getRole возвращает роль из хранилища; неизвестная роль считается неверным параметром
+func (uc *UserUseCase) getRole(ctx context.Context, name string) (*entity.Role, error) {
+	role, err := uc.RoleRepository.GetRole(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: неизвестная роль %q", ErrInvalidParams, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении роли: %w", err)
+	}
+	return role, nil
+}
+
+// GetGrants возвращает разрешения на фичи, выданные пользователю userID, или все разрешения при userID == 0;
+// требует разрешения user:manage
+func (uc *UserUseCase) GetGrants(ctx context.Context, userID int, token string) ([]*entity.PermissionGrant, error) {
+	// Проверка разрешения токена
+	if _, err := uc.TokenService.Authorize(token, entity.PermissionUserManage); err != nil {
+		return nil, authError(err)
+	}
+
+	grants, err := uc.GrantRepository.GetGrants(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении разрешений: %w", err)
+	}
+
+	return grants, nil
+}
+
+// CreateGrant выдает пользователю разрешение на одну фичу; требует разрешения user:manage.
+// Повторная выдача того же разрешения возвращает уже существующую запись
+func (uc *UserUseCase) CreateGrant(ctx context.Context, grant *entity.PermissionGrant, token string) (*entity.PermissionGrant, error) {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return nil, authError(err)
+	}
+
+	if !entity.IsFeatureScoped(grant.Permission) {
+		return nil, fmt.Errorf("%w: разрешение %q нельзя выдать на фичу", ErrInvalidParams, grant.Permission)
+	}
+	if grant.FeatureID <= 0 {
+		return nil, fmt.Errorf("%w: неверный ID фичи", ErrInvalidParams)
+	}
+
+	_, err = uc.UserRepository.GetUserByID(ctx, grant.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: пользователь %d не найден", ErrInvalidParams, grant.UserID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении пользователя: %w", err)
+	}
+
+	if err := uc.GrantRepository.CreateGrant(ctx, grant); err != nil {
+		return nil, fmt.Errorf("ошибка при выдаче разрешения: %w", err)
+	}
+
+	uc.Audit.Record(ctx, claims, entity.AuditCreate, entity.AuditEntityGrant, grant.ID, nil, grant)
+
+	return grant, nil
+}
+
+// DeleteGrant отзывает разрешение на фичу; требует разрешения user:manage.
+// Разрешения проверяются при каждом запросе, поэтому отзыв действует сразу
+func (uc *UserUseCase) DeleteGrant(ctx context.Context, id int, token string) error {
+	// Проверка разрешения токена
+	claims, err := uc.TokenService.Authorize(token, entity.PermissionUserManage)
+	if err != nil {
+		return authError(err)
+	}
+
+	before, err := uc.GrantRepository.DeleteGrant(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w", ErrGrantNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при отзыве разрешения: %w", err)
+	}
+
+	uc.Audit.Record(ctx, claims, entity.AuditDelete, entity.AuditEntityGrant, id, before, nil)
+
 	return nil
 }